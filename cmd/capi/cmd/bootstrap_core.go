@@ -35,6 +35,7 @@ var capiCoreCmd = &cobra.Command{
 			BootstrapProviders:      options.BootstrapProviders,
 			InfrastructureProviders: []infrastructure.Provider{},
 			ControlPlaneProviders:   options.ControlPlaneProviders,
+			CoreOnly:                true,
 		})
 		if err != nil {
 			return err
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"context"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -72,7 +73,7 @@ var capiInfraCmd = &cobra.Command{
 
 func init() {
 	bootstrapCmd.AddCommand(capiInfraCmd)
-	capiInfraCmd.PersistentFlags().StringSliceVar(&options.InfrastructureProviders, "providers", []string{"aws"}, "Name(s) of infra provider(s) to init")
+	capiInfraCmd.PersistentFlags().StringSliceVar(&options.InfrastructureProviders, "providers", []string{"aws"}, "Name(s) of infra provider(s) to init, one of: "+strings.Join(infrastructure.SupportedProviders(), ", "))
 	capiInfraCmd.PersistentFlags().StringVar(&targetNS, "target-ns", "", "Namespace to install proivder in")
 	capiInfraCmd.PersistentFlags().StringVar(&watchingNS, "watching-ns", "", "Namespace for provider to watch")
 
@@ -80,4 +81,18 @@ func init() {
 	opts := infrastructure.NewAWSSetupOptions()
 	capiInfraCmd.PersistentFlags().StringVar(&opts.AWSCredentials, "aws-base64-encoded-credentials", awsOptions.b64EncodedCredentials, "AWS_B64ENCODED_CREDENTIALS")
 	setupOptions[constants.AWSProviderName] = opts
+
+	// GCP provider flags
+	gcpOpts := infrastructure.NewGCPSetupOptions()
+	capiInfraCmd.PersistentFlags().StringVar(&gcpOpts.GCPCredentials, "gcp-base64-encoded-credentials", gcpOpts.GCPCredentials, "GCP_B64ENCODED_CREDENTIALS")
+	capiInfraCmd.PersistentFlags().StringVar(&gcpOpts.GCPProject, "gcp-project", gcpOpts.GCPProject, "GCP_PROJECT")
+	setupOptions[constants.GCPProviderName] = gcpOpts
+
+	// Azure provider flags
+	azureOpts := infrastructure.NewAzureSetupOptions()
+	capiInfraCmd.PersistentFlags().StringVar(&azureOpts.AzureSubscriptionID, "azure-subscription-id", azureOpts.AzureSubscriptionID, "AZURE_SUBSCRIPTION_ID")
+	capiInfraCmd.PersistentFlags().StringVar(&azureOpts.AzureTenantID, "azure-tenant-id", azureOpts.AzureTenantID, "AZURE_TENANT_ID")
+	capiInfraCmd.PersistentFlags().StringVar(&azureOpts.AzureClientID, "azure-client-id", azureOpts.AzureClientID, "AZURE_CLIENT_ID")
+	capiInfraCmd.PersistentFlags().StringVar(&azureOpts.AzureClientSecret, "azure-client-secret", azureOpts.AzureClientSecret, "AZURE_CLIENT_SECRET")
+	setupOptions[constants.AzureProviderName] = azureOpts
 }
@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"context"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -22,6 +23,10 @@ var deployOptions = capi.DefaultDeployOptions()
 
 var awsDeployOptions = infrastructure.NewAWSDeployOptions()
 
+var gcpDeployOptions = infrastructure.NewGCPDeployOptions()
+
+var azureDeployOptions = infrastructure.NewAzureDeployOptions()
+
 var clusterCreateCmd = &cobra.Command{
 	Use:   "create",
 	Short: "Deploy a cluster using CAPI.",
@@ -41,6 +46,20 @@ var clusterCreateCmd = &cobra.Command{
 			)
 		}
 
+		if deployOptions.Provider == constants.GCPProviderName {
+			opts = append(
+				opts,
+				capi.WithProviderOptions(gcpDeployOptions),
+			)
+		}
+
+		if deployOptions.Provider == constants.AzureProviderName {
+			opts = append(
+				opts,
+				capi.WithProviderOptions(azureDeployOptions),
+			)
+		}
+
 		if clusterCreateCmdFlags.templatePath != "" {
 			opts = append(opts, capi.WithTemplateFile(clusterCreateCmdFlags.templatePath))
 		}
@@ -61,7 +80,7 @@ func init() {
 		"https://github.com/talos-systems/cluster-api-templates/blob/main/aws/standard/standard.yaml", "Custom path for the cluster template")
 	clusterCreateCmd.Flags().Int64Var(&deployOptions.ControlPlaneNodes, "control-plane-nodes", deployOptions.ControlPlaneNodes, "Number of control plane nodes to deploy")
 	clusterCreateCmd.Flags().Int64Var(&deployOptions.WorkerNodes, "worker-nodes", deployOptions.WorkerNodes, "Number of worker nodes to deploy")
-	clusterCreateCmd.Flags().StringVarP(&deployOptions.Provider, "provider", "p", deployOptions.Provider, "Infrastructure provider to use for the deployment")
+	clusterCreateCmd.Flags().StringVarP(&deployOptions.Provider, "provider", "p", deployOptions.Provider, "Infrastructure provider to use for the deployment, one of: "+strings.Join(infrastructure.SupportedProviders(), ", "))
 	clusterCreateCmd.Flags().StringVar(&deployOptions.ProviderVersion, "provider-version", deployOptions.ProviderVersion, "Provider version to use")
 	clusterCreateCmd.Flags().StringVar(&deployOptions.KubernetesVersion, "kubernetes-version", deployOptions.KubernetesVersion, "Kubernetes version to use")
 	clusterCreateCmd.Flags().StringVar(&deployOptions.TalosVersion, "talos-version", deployOptions.TalosVersion, "Talos version to use")
@@ -84,4 +103,19 @@ func init() {
 	clusterCreateCmd.Flags().StringVar(&awsDeployOptions.Subnet, "aws-subnet", awsDeployOptions.NodeADDLSecGroups, "AWS subnet")
 	clusterCreateCmd.Flags().StringVar(&awsDeployOptions.SSHKeyName, "aws-ssh-key-name", awsDeployOptions.SSHKeyName, "AWS ssh key name")
 	clusterCreateCmd.Flags().StringVar(&awsDeployOptions.VPCID, "aws-vpc-id", awsDeployOptions.VPCID, "AWS VPC ID")
+
+	// GCP provider flags
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.Project, "gcp-project", gcpDeployOptions.Project, "GCP project")
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.Region, "gcp-region", gcpDeployOptions.Region, "GCP region")
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.Network, "gcp-network", gcpDeployOptions.Network, "GCP network name")
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.ControlPlaneMachineType, "gcp-cp-machine-type", gcpDeployOptions.ControlPlaneMachineType, "GCP control plane machine type")
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.NodeMachineType, "gcp-worker-machine-type", gcpDeployOptions.NodeMachineType, "GCP worker machine type")
+	clusterCreateCmd.Flags().StringVar(&gcpDeployOptions.ImageID, "gcp-image-id", gcpDeployOptions.ImageID, "GCP image ID")
+
+	// Azure provider flags
+	clusterCreateCmd.Flags().StringVar(&azureDeployOptions.Location, "azure-location", azureDeployOptions.Location, "Azure location")
+	clusterCreateCmd.Flags().StringVar(&azureDeployOptions.ResourceGroup, "azure-resource-group", azureDeployOptions.ResourceGroup, "Azure resource group")
+	clusterCreateCmd.Flags().StringVar(&azureDeployOptions.VNetName, "azure-vnet-name", azureDeployOptions.VNetName, "Azure VNet name")
+	clusterCreateCmd.Flags().StringVar(&azureDeployOptions.ControlPlaneMachineType, "azure-cp-machine-type", azureDeployOptions.ControlPlaneMachineType, "Azure control plane machine type")
+	clusterCreateCmd.Flags().StringVar(&azureDeployOptions.NodeMachineType, "azure-worker-machine-type", azureDeployOptions.NodeMachineType, "Azure worker machine type")
 }
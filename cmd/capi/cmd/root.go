@@ -13,6 +13,8 @@ import (
 
 	"github.com/spf13/cobra"
 	debug "github.com/talos-systems/go-debug"
+
+	"github.com/talos-systems/capi-utils/pkg/capi"
 )
 
 const (
@@ -36,6 +38,10 @@ func Execute() {
 		}
 	}()
 
+	if err := capi.CleanupTempFiles(); err != nil {
+		fmt.Printf("warning: failed to clean up temp files: %s\n", err)
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,142 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ErrClusterResourceSetsNotSupported is returned when ClusterResourceSet CRDs are not installed,
+// usually because ClusterResourceSet feature gate is disabled.
+var ErrClusterResourceSetsNotSupported = errors.New("cluster resource sets are not supported by the management cluster, check that ClusterResourceSet feature gate is enabled")
+
+// ResourceSetBinding is the ClusterResourceSet resource delivery state for the cluster.
+type ResourceSetBinding struct {
+	ClusterResourceSet string
+	Kind               string
+	Name               string
+	Applied            bool
+}
+
+// ResourceSetBindings lists resources of all ClusterResourceSets bound to the cluster.
+//
+// Resources which are not applied yet or not yet recorded in the binding are reported with Applied set to false.
+func (cluster *Cluster) ResourceSetBindings(ctx context.Context) ([]ResourceSetBinding, error) {
+	binding := &unstructured.Unstructured{}
+	binding.SetGroupVersionKind(cluster.addonsGVK("ClusterResourceSetBinding"))
+
+	// binding is named after the cluster
+	if err := cluster.manager.runtimeClient.Get(ctx, types.NamespacedName{Name: cluster.name, Namespace: cluster.namespace}, binding); err != nil {
+		switch {
+		case meta.IsNoMatchError(err):
+			return nil, ErrClusterResourceSetsNotSupported
+		case apierrors.IsNotFound(err):
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	bindings, _, err := unstructured.NestedSlice(binding.Object, "spec", "bindings")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []ResourceSetBinding
+
+	for _, b := range bindings {
+		setBinding, ok := b.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		setName, _, err := unstructured.NestedString(setBinding, "clusterResourceSetName")
+		if err != nil {
+			return nil, err
+		}
+
+		applied := map[string]bool{}
+
+		resources, _, err := unstructured.NestedSlice(setBinding, "resources")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range resources {
+			resource, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			kind, name := resourceKindName(resource)
+			applied[kind+"/"+name], _, _ = unstructured.NestedBool(resource, "applied")
+		}
+
+		setResources, err := cluster.resourceSetResources(ctx, setName)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resource := range setResources {
+			kind, name := resourceKindName(resource)
+
+			res = append(res, ResourceSetBinding{
+				ClusterResourceSet: setName,
+				Kind:               kind,
+				Name:               name,
+				Applied:            applied[kind+"/"+name],
+			})
+		}
+	}
+
+	return res, nil
+}
+
+// resourceSetResources returns spec.resources of the ClusterResourceSet.
+func (cluster *Cluster) resourceSetResources(ctx context.Context, name string) ([]map[string]interface{}, error) {
+	set := &unstructured.Unstructured{}
+	set.SetGroupVersionKind(cluster.addonsGVK("ClusterResourceSet"))
+
+	if err := cluster.manager.runtimeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: cluster.namespace}, set); err != nil {
+		return nil, err
+	}
+
+	resources, _, err := unstructured.NestedSlice(set.Object, "spec", "resources")
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]map[string]interface{}, 0, len(resources))
+
+	for _, r := range resources {
+		if resource, ok := r.(map[string]interface{}); ok {
+			res = append(res, resource)
+		}
+	}
+
+	return res, nil
+}
+
+func resourceKindName(resource map[string]interface{}) (string, string) {
+	kind, _, _ := unstructured.NestedString(resource, "kind")
+	name, _, _ := unstructured.NestedString(resource, "name")
+
+	return kind, name
+}
+
+func (cluster *Cluster) addonsGVK(kind string) schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Group:   "addons.cluster.x-k8s.io",
+		Kind:    kind,
+		Version: cluster.manager.version,
+	}
+}
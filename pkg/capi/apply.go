@@ -0,0 +1,171 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// applySetLabel marks objects applied by Apply with the apply set name.
+const applySetLabel = "capi-utils.talos-systems.com/apply-set"
+
+// ApplyOptions defines optional parameters of Apply.
+type ApplyOptions struct {
+	// ApplySet is the name of the set of applied objects, all applied objects are labeled with it.
+	ApplySet string
+
+	// Prune deletes objects of the apply set which are not in the manifests anymore, ApplySet is required.
+	Prune bool
+
+	// PruneSelector narrows down objects which might be pruned, nil selects all the apply set objects.
+	PruneSelector labels.Selector
+}
+
+// ApplyOption optional Apply parameter setter.
+type ApplyOption func(*ApplyOptions)
+
+// WithApplySet labels applied objects with the apply set name.
+func WithApplySet(name string) ApplyOption {
+	return func(o *ApplyOptions) {
+		o.ApplySet = name
+	}
+}
+
+// WithPrune deletes objects of the apply set missing from the manifests, similar to kubectl apply --prune.
+//
+// Only objects matching the selector are pruned, nil selector prunes any object of the apply set,
+// selector which matches nothing (e.g. labels.Nothing()) disables pruning.
+func WithPrune(selector labels.Selector) ApplyOption {
+	return func(o *ApplyOptions) {
+		o.Prune = true
+		o.PruneSelector = selector
+	}
+}
+
+// Apply creates or updates objects from the manifests using server-side apply.
+//
+// With pruning enabled, objects of the apply set which were applied before, but are not in the manifests anymore are deleted.
+// Pruned kinds are the kinds present in the manifests and all cluster API kinds.
+func (clusterAPI *Manager) Apply(ctx context.Context, manifests []byte, setters ...ApplyOption) error {
+	var opts ApplyOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	if opts.Prune && opts.ApplySet == "" {
+		return fmt.Errorf("apply set name is required for pruning")
+	}
+
+	objs, err := utilyaml.ToUnstructured(manifests)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifests %w", err)
+	}
+
+	type objectKey struct {
+		gk schema.GroupKind
+		types.NamespacedName
+	}
+
+	applied := map[objectKey]struct{}{}
+	// kinds to prune are deduplicated by group and kind, as the manifests and the API might use different versions
+	kinds := map[schema.GroupKind]schema.GroupVersionKind{}
+
+	for i := range objs {
+		obj := &objs[i]
+
+		if opts.ApplySet != "" {
+			objLabels := obj.GetLabels()
+			if objLabels == nil {
+				objLabels = map[string]string{}
+			}
+
+			objLabels[applySetLabel] = opts.ApplySet
+
+			obj.SetLabels(objLabels)
+		}
+
+		if err = clusterAPI.runtimeClient.Patch(ctx, obj, runtimeclient.Apply, runtimeclient.ForceOwnership, runtimeclient.FieldOwner(fieldOwner)); err != nil {
+			return fmt.Errorf("failed to apply %s %s/%s %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+
+		applied[objectKey{obj.GroupVersionKind().GroupKind(), types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}] = struct{}{}
+		kinds[obj.GroupVersionKind().GroupKind()] = obj.GroupVersionKind()
+	}
+
+	if !opts.Prune {
+		return nil
+	}
+
+	selector := labels.NewSelector()
+
+	if opts.PruneSelector != nil {
+		requirements, selectable := opts.PruneSelector.Requirements()
+		if !selectable {
+			// selector matches nothing, e.g. labels.Nothing()
+			return nil
+		}
+
+		selector = selector.Add(requirements...)
+	}
+
+	capiKinds, err := clusterAPI.capiKinds()
+	if err != nil {
+		return err
+	}
+
+	for _, gvk := range capiKinds {
+		if _, ok := kinds[gvk.GroupKind()]; !ok {
+			kinds[gvk.GroupKind()] = gvk
+		}
+	}
+
+	requirement, err := labels.NewRequirement(applySetLabel, selection.Equals, []string{opts.ApplySet})
+	if err != nil {
+		return err
+	}
+
+	selector = selector.Add(*requirement)
+
+	for gk, gvk := range kinds {
+		var list unstructured.UnstructuredList
+
+		list.SetGroupVersionKind(gvk)
+
+		if err = clusterAPI.runtimeClient.List(ctx, &list, runtimeclient.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return fmt.Errorf("failed to list %s objects to prune %w", gvk.Kind, err)
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+
+			if _, ok := applied[objectKey{gk, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}]; ok {
+				continue
+			}
+
+			if obj.GetDeletionTimestamp() != nil {
+				continue
+			}
+
+			fmt.Printf("pruning %s %s/%s\n", gvk.Kind, obj.GetNamespace(), obj.GetName())
+
+			if err = clusterAPI.runtimeClient.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to prune %s %s/%s %w", gvk.Kind, obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+	}
+
+	return nil
+}
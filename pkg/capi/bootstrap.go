@@ -0,0 +1,209 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+const redacted = "******"
+
+// redactedKeys are the machine config and bootstrap payload keys holding secrets.
+//
+// Values under these keys are redacted as a whole, including nested objects and lists,
+// e.g. machine.ca (crt and key), registries auth or kubeadm files content.
+var redactedKeys = map[string]struct{}{
+	"key":                       {},
+	"crt":                       {},
+	"privateKey":                {},
+	"token":                     {},
+	"secret":                    {},
+	"aescbcEncryptionSecret":    {},
+	"secretboxEncryptionSecret": {},
+	"bootstrapToken":            {},
+	"certificateKey":            {},
+	"auth":                      {},
+	"password":                  {},
+	"passwd":                    {},
+	"identityToken":             {},
+	"content":                   {},
+}
+
+// BootstrapDataOptions defines additional optional parameters for bootstrap data method.
+type BootstrapDataOptions struct {
+	IncludeSecrets bool
+}
+
+// BootstrapDataOption optional bootstrap data parameter setter.
+type BootstrapDataOption func(*BootstrapDataOptions)
+
+// IncludeSecrets disables redaction of the secrets in the bootstrap data.
+func IncludeSecrets() BootstrapDataOption {
+	return func(opts *BootstrapDataOptions) {
+		opts.IncludeSecrets = true
+	}
+}
+
+// BootstrapData returns the bootstrap data (machine config) generated for the Machine.
+//
+// Keys, tokens and other secrets are redacted unless IncludeSecrets is set.
+func (cluster *Cluster) BootstrapData(ctx context.Context, machineName string, setters ...BootstrapDataOption) ([]byte, error) {
+	var opts BootstrapDataOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	secretName, err := cluster.bootstrapDataSecretName(ctx, machineName)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret v1.Secret
+
+	if err = cluster.manager.runtimeClient.Get(ctx, types.NamespacedName{Namespace: cluster.namespace, Name: secretName}, &secret); err != nil {
+		return nil, err
+	}
+
+	data, ok := secret.Data["value"]
+	if !ok {
+		return nil, fmt.Errorf("bootstrap data secret %s has no value", secretName)
+	}
+
+	if opts.IncludeSecrets {
+		return data, nil
+	}
+
+	return redactConfig(data)
+}
+
+// redactConfig parses the machine config or cloud-init bootstrap payload and redacts the secrets.
+func redactConfig(data []byte) ([]byte, error) {
+	var config map[string]interface{}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse bootstrap data for redaction %w", err)
+	}
+
+	redact(config)
+
+	return yaml.Marshal(config)
+}
+
+// bootstrapDataSecretName resolves the Machine bootstrap data secret either directly or via the bootstrap config.
+func (cluster *Cluster) bootstrapDataSecretName(ctx context.Context, machineName string) (string, error) {
+	var machine unstructured.Unstructured
+
+	machine.SetGroupVersionKind(schema.GroupVersionKind{
+		Version: cluster.manager.version,
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Machine",
+	})
+
+	if err := cluster.manager.runtimeClient.Get(ctx, types.NamespacedName{Namespace: cluster.namespace, Name: machineName}, &machine); err != nil {
+		return "", err
+	}
+
+	secretName, found, err := unstructured.NestedString(machine.Object, "spec", "bootstrap", "dataSecretName")
+	if err != nil {
+		return "", err
+	}
+
+	if found && secretName != "" {
+		return secretName, nil
+	}
+
+	configRef, err := getRef(machine.Object, "spec", "bootstrap", "configRef")
+	if err != nil {
+		return "", err
+	}
+
+	var config unstructured.Unstructured
+
+	config.SetGroupVersionKind(configRef.gvk)
+
+	if err = cluster.manager.runtimeClient.Get(ctx, configRef.NamespacedName, &config); err != nil {
+		return "", err
+	}
+
+	if secretName, found, err = unstructured.NestedString(config.Object, "status", "dataSecretName"); err != nil {
+		return "", err
+	}
+
+	if !found || secretName == "" {
+		return "", fmt.Errorf("%s %s has no bootstrap data generated yet", config.GetKind(), config.GetName())
+	}
+
+	return secretName, nil
+}
+
+// redact replaces the values of the secret keys in place.
+//
+// JSON patches (e.g. Talos configPatches) setting a secret key, like /machine/token, are redacted as well.
+func redact(in interface{}) {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		if path, ok := v["path"].(string); ok {
+			if _, hasValue := v["value"]; hasValue && isRedactedPath(path) {
+				v["value"] = redactAll(v["value"])
+			}
+		}
+
+		for key, value := range v {
+			if _, ok := redactedKeys[key]; ok {
+				v[key] = redactAll(value)
+
+				continue
+			}
+
+			redact(value)
+		}
+	case []interface{}:
+		for _, value := range v {
+			redact(value)
+		}
+	}
+}
+
+// redactAll replaces all the scalar values, keeping the structure.
+func redactAll(in interface{}) interface{} {
+	switch v := in.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = redactAll(value)
+		}
+
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactAll(value)
+		}
+
+		return v
+	case nil:
+		return nil
+	default:
+		return redacted
+	}
+}
+
+// isRedactedPath checks if any of the JSON pointer path tokens is a secret key.
+func isRedactedPath(path string) bool {
+	for _, token := range strings.Split(path, "/") {
+		if _, ok := redactedKeys[strings.NewReplacer("~1", "/", "~0", "~").Replace(token)]; ok {
+			return true
+		}
+	}
+
+	return false
+}
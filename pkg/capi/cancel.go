@@ -0,0 +1,193 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CancelOptions defines optional parameters of CancelProvisioning.
+type CancelOptions struct {
+	// DeleteOnCancel deletes the whole cluster instead of keeping it for the retry.
+	DeleteOnCancel bool
+}
+
+// CancelOption optional CancelProvisioning parameter setter.
+type CancelOption func(*CancelOptions)
+
+// DeleteOnCancel makes CancelProvisioning delete the cluster.
+func DeleteOnCancel() CancelOption {
+	return func(o *CancelOptions) {
+		o.DeleteOnCancel = true
+	}
+}
+
+// CancelProvisioning aborts provisioning of the cluster which is not provisioned yet.
+//
+// Machine owners (control plane, MachineDeployments and MachineSets) are paused with the paused annotation,
+// so that they don't create new Machines, and all the cluster Machines are deleted releasing their infrastructure.
+// The Cluster object is kept, provisioning is restarted by ResumeProvisioning.
+// Cluster spec.paused is not used, as it blocks Machine deletion as well.
+//
+// With DeleteOnCancel the cluster is destroyed instead.
+func (cluster *Cluster) CancelProvisioning(ctx context.Context, setters ...CancelOption) error {
+	var opts CancelOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	if err := cluster.sync(ctx); err != nil {
+		return err
+	}
+
+	phase, _, err := unstructured.NestedString(cluster.cluster.Object, "status", "phase")
+	if err != nil {
+		return err
+	}
+
+	controlPlaneReady, _, err := unstructured.NestedBool(cluster.cluster.Object, "status", "controlPlaneReady")
+	if err != nil {
+		return err
+	}
+
+	if clusterv1.ClusterPhase(phase) == clusterv1.ClusterPhaseProvisioned && controlPlaneReady {
+		return fmt.Errorf("cluster %s/%s is already provisioned", cluster.namespace, cluster.name)
+	}
+
+	if opts.DeleteOnCancel {
+		return cluster.manager.DestroyCluster(ctx, cluster.name, cluster.namespace)
+	}
+
+	owners, err := cluster.machineOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range owners {
+		if err = cluster.setPausedAnnotation(ctx, &owners[i], true); err != nil {
+			return err
+		}
+	}
+
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+
+		if machine.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		if err = cluster.manager.runtimeClient.Delete(ctx, machine); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete machine %s %w", machine.GetName(), err)
+		}
+
+		fmt.Printf("deleting machine %s/%s\n", cluster.namespace, machine.GetName())
+	}
+
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		machines, err := cluster.machines(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		if len(machines.Items) > 0 {
+			return retry.ExpectedError(fmt.Errorf("%d machines are being deleted", len(machines.Items)))
+		}
+
+		return nil
+	})
+}
+
+// ResumeProvisioning removes the paused annotation set by CancelProvisioning, so that the Machines are created again.
+func (cluster *Cluster) ResumeProvisioning(ctx context.Context) error {
+	if err := cluster.sync(ctx); err != nil {
+		return err
+	}
+
+	owners, err := cluster.machineOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range owners {
+		if err = cluster.setPausedAnnotation(ctx, &owners[i], false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// machineOwners returns the control plane, MachineDeployments and MachineSets of the cluster.
+func (cluster *Cluster) machineOwners(ctx context.Context) ([]unstructured.Unstructured, error) {
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []unstructured.Unstructured{*controlPlane}
+
+	for _, kind := range []string{"MachineDeployment", "MachineSet"} {
+		var list unstructured.UnstructuredList
+
+		list.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "cluster.x-k8s.io",
+			Kind:    kind,
+			Version: cluster.manager.version,
+		})
+
+		if err = cluster.manager.runtimeClient.List(ctx, &list,
+			runtimeclient.InNamespace(cluster.namespace),
+			runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: cluster.name},
+		); err != nil {
+			return nil, err
+		}
+
+		res = append(res, list.Items...)
+	}
+
+	return res, nil
+}
+
+// setPausedAnnotation adds or removes CAPI paused annotation on the object.
+func (cluster *Cluster) setPausedAnnotation(ctx context.Context, obj *unstructured.Unstructured, paused bool) error {
+	annotations := obj.GetAnnotations()
+
+	if _, ok := annotations[clusterv1.PausedAnnotation]; ok == paused {
+		return nil
+	}
+
+	if paused {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		annotations[clusterv1.PausedAnnotation] = ""
+	} else {
+		delete(annotations, clusterv1.PausedAnnotation)
+	}
+
+	obj.SetAnnotations(annotations)
+
+	if err := cluster.manager.runtimeClient.Update(ctx, obj); err != nil {
+		return fmt.Errorf("failed to update %s %s paused annotation %w", obj.GetKind(), obj.GetName(), err)
+	}
+
+	return nil
+}
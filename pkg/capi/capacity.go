@@ -0,0 +1,145 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// etcdDBSizeMetrics are the API server metrics reporting etcd database size, names differ between Kubernetes versions.
+var etcdDBSizeMetrics = []string{"apiserver_storage_db_total_size_in_bytes", "etcd_db_total_size_in_bytes"}
+
+// nodePressureConditions are the node conditions which signal resource pressure.
+var nodePressureConditions = []corev1.NodeConditionType{corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure}
+
+// CapacityReport describes management cluster resource pressure.
+type CapacityReport struct {
+	// NodePressure lists node pressure conditions in the form <node>: <condition>.
+	NodePressure []string
+
+	// EtcdChecked is set if the API server etcd health check is accessible, EtcdHealthy is the check result.
+	// etcd reports unhealthy with active alarms, e.g. NOSPACE.
+	EtcdChecked bool
+	EtcdHealthy bool
+
+	// EtcdDBSize is the largest etcd database size in bytes reported by the API server, zero if metrics are not accessible.
+	EtcdDBSize int64
+}
+
+// OK returns true if there is no resource pressure detected.
+func (report *CapacityReport) OK() bool {
+	return len(report.NodePressure) == 0 && (!report.EtcdChecked || report.EtcdHealthy)
+}
+
+func (report *CapacityReport) String() string {
+	problems := append([]string{}, report.NodePressure...)
+
+	if report.EtcdChecked && !report.EtcdHealthy {
+		problems = append(problems, "etcd is not healthy")
+	}
+
+	if len(problems) == 0 {
+		return "no resource pressure"
+	}
+
+	return strings.Join(problems, ", ")
+}
+
+// CheckCapacity inspects management cluster nodes for resource pressure and etcd health and database size if accessible.
+func (clusterAPI *Manager) CheckCapacity(ctx context.Context) (*CapacityReport, error) {
+	nodes, err := clusterAPI.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CapacityReport{}
+
+	for _, node := range nodes.Items {
+		for _, cond := range node.Status.Conditions {
+			for _, pressure := range nodePressureConditions {
+				if cond.Type == pressure && cond.Status == corev1.ConditionTrue {
+					report.NodePressure = append(report.NodePressure, fmt.Sprintf("%s: %s", node.Name, cond.Type))
+				}
+			}
+		}
+	}
+
+	restClient := clusterAPI.clientset.CoreV1().RESTClient()
+
+	var statusCode int
+
+	restClient.Get().AbsPath("/readyz/etcd").Do(ctx).StatusCode(&statusCode)
+
+	switch statusCode {
+	case 0, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		// health check is not accessible
+	default:
+		report.EtcdChecked = true
+		report.EtcdHealthy = statusCode == http.StatusOK
+	}
+
+	if metrics, err := restClient.Get().AbsPath("/metrics").DoRaw(ctx); err == nil {
+		report.EtcdDBSize = etcdDBSize(metrics)
+	}
+
+	return report, nil
+}
+
+// etcdDBSize returns the largest etcd database size from API server metrics in Prometheus text format.
+func etcdDBSize(metrics []byte) int64 {
+	var size int64
+
+	scanner := bufio.NewScanner(bytes.NewReader(metrics))
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		for _, metric := range etcdDBSizeMetrics {
+			if !strings.HasPrefix(line, metric+"{") && !strings.HasPrefix(line, metric+" ") {
+				continue
+			}
+
+			fields := strings.Fields(line)
+
+			value, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+			if err != nil {
+				continue
+			}
+
+			if int64(value) > size {
+				size = int64(value)
+			}
+		}
+	}
+
+	return size
+}
+
+// waitCapacity waits until the management cluster has no resource pressure.
+func (clusterAPI *Manager) waitCapacity(ctx context.Context) error {
+	return retry.Constant(30*time.Minute, retry.WithUnits(30*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		report, err := clusterAPI.CheckCapacity(ctx)
+		if err != nil {
+			return clusterAPI.retryable(err)
+		}
+
+		if !report.OK() {
+			return retry.ExpectedError(fmt.Errorf("management cluster is under pressure: %s", report))
+		}
+
+		return nil
+	})
+}
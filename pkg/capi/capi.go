@@ -11,18 +11,23 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
-	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"github.com/talos-systems/go-retry/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	clientcmd "k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/tools/record"
 	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
 	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/cluster"
@@ -30,13 +35,25 @@ import (
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
-	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const (
+	// fieldOwner is the field manager name used for server-side apply.
+	fieldOwner = "capi-utils"
+
+	// defaultConnectTimeout bounds API calls done by NewManager.
+	defaultConnectTimeout = 30 * time.Second
+
+	// defaultQPS and defaultBurst are raised from the client-go defaults (5 and 10) for batch cluster operations.
+	defaultQPS   = 50
+	defaultBurst = 100
 )
 
 // Manager installs and controls cluster API installation.
 type Manager struct {
 	kubeconfig    client.Kubeconfig
 	client        client.Client
+	configClient  config.Client
 	clientset     *kubernetes.Clientset
 	config        *rest.Config
 	runtimeClient runtimeclient.Client
@@ -44,6 +61,12 @@ type Manager struct {
 	providers     []infrastructure.Provider
 	cfg           *Config
 
+	// resolvedVersions maps infrastructure provider names to the versions resolved from the version ranges.
+	resolvedVersions map[string]string
+
+	// tempDirs are the temp directories used by the Manager until Close, e.g. pulled OCI providers.
+	tempDirs []string
+
 	options Options
 }
 
@@ -52,31 +75,189 @@ type Options struct {
 	Proxy                   cluster.Proxy
 	Kubeconfig              client.Kubeconfig
 	ClusterctlConfigPath    string
-	CoreProvider            string
+	ClusterctlConfigBytes   []byte
+	CoreProvider            string // cluster-api[:version], version defaults to the cluster-api module version capi-utils is built with
 	ContextName             string
 	InfrastructureProviders []infrastructure.Provider
 	BootstrapProviders      []string
 	ControlPlaneProviders   []string
 	WaitProviderTimeout     time.Duration
+
+	// ProviderResources sets resource requests and limits on the provider controller containers after install.
+	// Keys are clusterctl provider labels, e.g. cluster-api, bootstrap-talos, infrastructure-aws.
+	ProviderResources map[string]corev1.ResourceRequirements
+
+	// ProviderReplicas sets replica count of the provider controller deployments after install.
+	// Keys are clusterctl provider labels, controllers should have leader election enabled to run more than one replica.
+	ProviderReplicas map[string]int32
+
+	// ProviderNamespaceAnnotations are set on all provider namespaces after install.
+	ProviderNamespaceAnnotations map[string]string
+
+	// ProviderDeploymentAnnotations are set on all provider controller deployments and their pod templates after install,
+	// e.g. to satisfy admission policies or to opt out of the sidecar injection.
+	ProviderDeploymentAnnotations map[string]string
+
+	// LocalProviderPath maps clusterctl provider labels to the directories with pre-downloaded metadata.yaml and components.yaml.
+	// Directories should follow clusterctl local repository layout: {basepath}/{provider-label}/{version}.
+	LocalProviderPath map[string]string
+
+	// EventRecorder records install lifecycle events for the EventObject, both should be set to enable events.
+	EventRecorder record.EventRecorder
+	EventObject   runtime.Object
+
+	// ConnectTimeout bounds management cluster API calls done while creating the Manager.
+	// Defaults to 30 seconds.
+	ConnectTimeout time.Duration
+
+	// AllowModifyExisting disables the check which prevents installing different provider versions
+	// into the management cluster which already has workload clusters.
+	AllowModifyExisting bool
+
+	// ProgressFunc is called at each Install phase boundary.
+	ProgressFunc func(ProgressEvent)
+
+	// RequireImageDigests rejects installing components which reference images by a tag instead of a digest.
+	// Images can be pinned using clusterctl config image overrides.
+	RequireImageDigests bool
+
+	// WriteManagementMetadata annotates the core CAPI namespace with capi-utils version and install time.
+	WriteManagementMetadata bool
+
+	// AllowDetach enables Cluster.Detach.
+	AllowDetach bool
+
+	// CoreOnly allows installing the core provider without infrastructure providers,
+	// e.g. if they are installed later with InstallProvider.
+	CoreOnly bool
+
+	// SkipCertManagerWait skips waiting for cert-manager webhook after core install,
+	// e.g. if preexisting cert-manager is known to be ready.
+	SkipCertManagerWait bool
+
+	// ProviderGVK pins the clusterctl inventory Provider kind, e.g. for clusterctl forks using a different group.
+	// If not set, Provider kind is detected via API discovery.
+	ProviderGVK schema.GroupVersionKind
+
+	// FeatureGates enables or disables experimental core provider features, e.g. ClusterTopology or MachinePool.
+	// Feature gates are applied only when the core provider is installed.
+	FeatureGates map[string]bool
+
+	// CertManagerNamespace is the namespace of cert-manager used by cert-manager checks, defaults to cert-manager.
+	// clusterctl installs cert-manager only into the default namespace, so custom namespace should have cert-manager preinstalled.
+	CertManagerNamespace string
+
+	// TolerateStateFetchErrors logs provider list errors while fetching the state instead of failing,
+	// the state is left empty in that case.
+	TolerateStateFetchErrors bool
+
+	// OCIProviderRepository maps clusterctl provider labels to OCI artifacts with provider metadata.yaml and components.yaml layers,
+	// e.g. ghcr.io/org/infrastructure-aws:v1.2.0. Reference tag defaults to the requested provider version.
+	OCIProviderRepository map[string]string
+
+	// RegistryAuth is used to pull OCI provider artifacts, docker config credentials are used if not set.
+	RegistryAuth *RegistryAuth
+
+	// RetryableErrorFunc classifies errors in the wait loops, including infrastructure providers WaitReady,
+	// as transient (retried) or fatal, defaults to DefaultRetryableError.
+	RetryableErrorFunc func(error) bool
+
+	// CredentialsSecretRef points to the secret with provider credentials, secret keys are used as clusterctl variables,
+	// e.g. AWS_B64ENCODED_CREDENTIALS. Secret values take precedence over the environment.
+	CredentialsSecretRef *types.NamespacedName
+
+	// TemplateProcessor renders cluster templates, defaults to clusterctl simple ${VAR} processor.
+	TemplateProcessor client.Processor
+
+	// DeleteClusterSecrets removes cluster secrets (kubeconfig, talosconfig, CA) which were not garbage collected
+	// once the cluster is destroyed. Only secrets labeled with the cluster name or owned by the Cluster are removed.
+	DeleteClusterSecrets bool
+
+	// QPS and Burst limit the requests rate of the Manager clients to the management cluster API server,
+	// defaults are 50 and 100. Higher values speed up bulk operations at the cost of more API server load,
+	// lower values should be used for shared management clusters.
+	// Limits don't apply to the clusterctl client.
+	QPS   float32
+	Burst int
+
+	// TempDir is the directory for the temp files (rendered templates, pulled OCI providers), defaults to os.TempDir().
+	// NewManager checks that the directory is writable.
+	TempDir string
 }
 
 // NewManager creates new Manager object.
-func NewManager(ctx context.Context, options Options) (*Manager, error) {
+//
+// Manager should be closed with Close once it is not needed anymore.
+func NewManager(ctx context.Context, options Options) (_ *Manager, err error) {
 	clusterAPI := &Manager{
 		options: options,
 		cfg:     newConfig(),
 	}
 
-	err := clusterAPI.cfg.Init(options.ClusterctlConfigPath)
+	defer func() {
+		if err != nil {
+			clusterAPI.Close() //nolint:errcheck
+		}
+	}()
+
+	if err = options.Validate(); err != nil {
+		return nil, err
+	}
+
+	clusterAPI.options.CoreProvider = defaultCoreProviderVersion(options.CoreProvider)
+
+	if err = checkTempDir(clusterAPI.tempDir()); err != nil {
+		return nil, err
+	}
+
+	if options.ClusterctlConfigBytes != nil {
+		err = clusterAPI.cfg.InitFromBytes(options.ClusterctlConfigBytes)
+	} else {
+		err = clusterAPI.cfg.Init(options.ClusterctlConfigPath)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	localProviders := map[string]string{}
+
+	for label, dir := range options.LocalProviderPath {
+		localProviders[label] = dir
+	}
+
+	ociProviders, err := clusterAPI.pullOCIProviders(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	for label, dir := range ociProviders {
+		if _, ok := localProviders[label]; ok {
+			return nil, fmt.Errorf("provider %s is set both in local provider path and OCI provider repository", label)
+		}
+
+		localProviders[label] = dir
+	}
+
+	if err = clusterAPI.configureLocalProviders(localProviders); err != nil {
+		return nil, err
+	}
+
+	if err = clusterAPI.configureFeatureGates(); err != nil {
+		return nil, err
+	}
+
 	configClient, err := config.New(options.ClusterctlConfigPath, config.InjectReader(clusterAPI.cfg))
 	if err != nil {
 		return nil, err
 	}
 
+	if err = options.validateProviderNames(configClient); err != nil {
+		return nil, err
+	}
+
+	clusterAPI.configClient = configClient
+
 	opts := []client.Option{
 		client.InjectConfig(configClient),
 	}
@@ -127,23 +308,61 @@ func NewManager(ctx context.Context, options Options) (*Manager, error) {
 		}
 	}
 
+	clusterAPI.config.QPS = options.QPS
+	if clusterAPI.config.QPS == 0 {
+		clusterAPI.config.QPS = defaultQPS
+	}
+
+	clusterAPI.config.Burst = options.Burst
+	if clusterAPI.config.Burst == 0 {
+		clusterAPI.config.Burst = defaultBurst
+	}
+
 	clusterAPI.clientset, err = kubernetes.NewForConfig(clusterAPI.config)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = clusterAPI.GetClient(ctx)
+	connectTimeout := options.ConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = defaultConnectTimeout
+	}
+
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	// fail fast if the API server is not reachable, as discovery calls can't be canceled
+	if err = pingAPIServer(connectCtx, clusterAPI.config); err != nil {
+		return nil, fmt.Errorf("failed to connect to the management cluster %w", err)
+	}
+
+	_, err = clusterAPI.GetClient(connectCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = clusterAPI.FetchState(ctx); err != nil {
+	if err = clusterAPI.FetchState(connectCtx); err != nil {
 		return nil, err
 	}
 
 	return clusterAPI, nil
 }
 
+// Close removes the temp files used by the Manager, Manager can't be used after Close.
+func (clusterAPI *Manager) Close() error {
+	var errs []error
+
+	for _, dir := range clusterAPI.tempDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	clusterAPI.tempDirs = nil
+
+	return utilerrors.NewAggregate(errs)
+}
+
 // GetKubeconfig returns kubeconfig in clusterctl expected format.
 func (clusterAPI *Manager) GetKubeconfig(ctx context.Context) (client.Kubeconfig, error) {
 	if clusterAPI.kubeconfig.Path != "" {
@@ -185,6 +404,13 @@ func (clusterAPI *Manager) GetClient(ctx context.Context) (client runtimeclient.
 	return clusterAPI.runtimeClient, err
 }
 
+// GetCachedClient returns k8s client reading from the informer cache with the field indexes.
+//
+// Cache is stopped once the context is canceled.
+func (clusterAPI *Manager) GetCachedClient(ctx context.Context, indexes ...FieldIndex) (runtimeclient.Client, error) {
+	return GetCachedMetalClient(ctx, clusterAPI.config, indexes...)
+}
+
 // GetClientSet returns a kubernetes clientset to use.
 func (clusterAPI *Manager) GetClientSet() *kubernetes.Clientset {
 	return clusterAPI.clientset
@@ -192,53 +418,228 @@ func (clusterAPI *Manager) GetClientSet() *kubernetes.Clientset {
 
 // Install the Manager components and wait for them to be ready.
 func (clusterAPI *Manager) Install(ctx context.Context) error {
+	clusterAPI.recordEvent(corev1.EventTypeNormal, "Installing", "installing cluster API components")
+
+	if err := clusterAPI.install(ctx); err != nil {
+		clusterAPI.recordEvent(corev1.EventTypeWarning, "InstallFailed", "failed to install cluster API components: %s", err)
+
+		return err
+	}
+
+	clusterAPI.recordEvent(corev1.EventTypeNormal, "Installed", "cluster API components are installed")
+
+	return nil
+}
+
+func (clusterAPI *Manager) install(ctx context.Context) error {
 	kubeconfig, err := clusterAPI.GetKubeconfig(ctx)
 	if err != nil {
 		return err
 	}
 
+	if err = clusterAPI.resolveVersionRanges(); err != nil {
+		return err
+	}
+
+	if err = clusterAPI.checkCoreProviderContract(ctx); err != nil {
+		return err
+	}
+
+	if !clusterAPI.options.AllowModifyExisting {
+		if err = clusterAPI.checkModifyExisting(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err = clusterAPI.applyCredentialsSecret(ctx); err != nil {
+		return err
+	}
+
 	// nb: We use the same call to Manager.Install for both core and infra installs
 	// This check ensures we don't try to install core if the provider string is empty,
 	// which it would be during an infra install
 	if clusterAPI.options.CoreProvider != "" {
+		if err = clusterAPI.checkCertManagerNamespace(ctx); err != nil {
+			return err
+		}
+
+		if err = clusterAPI.checkManagementKubernetesVersion(ctx); err != nil {
+			return err
+		}
+
 		err = clusterAPI.InstallCore(ctx, kubeconfig)
 		if err != nil {
 			return err
 		}
+
+		if !clusterAPI.options.SkipCertManagerWait {
+			if err = clusterAPI.waitCertManagerWebhook(ctx); err != nil {
+				return err
+			}
+
+			clusterAPI.progress(ProgressPhaseCertManagerReady, "")
+		}
+	}
+
+	providers, err := infrastructure.InstallOrder(clusterAPI.options.InfrastructureProviders)
+	if err != nil {
+		return err
 	}
 
-	for _, provider := range clusterAPI.options.InfrastructureProviders {
+	for _, provider := range providers {
 		err = clusterAPI.InstallProvider(ctx, kubeconfig, provider)
 		if err != nil {
 			return err
 		}
 	}
 
-	for _, provider := range clusterAPI.options.InfrastructureProviders {
-		if err = provider.WaitReady(ctx, clusterAPI.clientset); err != nil {
+	for _, provider := range providers {
+		if err = clusterAPI.waitProviderReady(ctx, provider); err != nil {
 			return err
 		}
+
+		if err = clusterAPI.waitProviderCR(ctx, provider.Name(), provider.Namespace(),
+			clusterAPI.requestedVersion(provider.Name(), clusterctlv1.InfrastructureProviderType)); err != nil {
+			return err
+		}
+
+		clusterAPI.progress(ProgressPhaseProviderReady, provider.Name())
+	}
+
+	if err = clusterAPI.WaitForCRDsEstablished(ctx); err != nil {
+		return err
+	}
+
+	if err = clusterAPI.patchProviderResources(ctx); err != nil {
+		return err
+	}
+
+	if err = clusterAPI.patchProviderReplicas(ctx); err != nil {
+		return err
+	}
+
+	if err = clusterAPI.patchProviderAnnotations(ctx); err != nil {
+		return err
+	}
+
+	if clusterAPI.options.WriteManagementMetadata {
+		if err = clusterAPI.writeManagementMetadata(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err = clusterAPI.FetchState(ctx); err != nil {
+		return err
+	}
+
+	clusterAPI.progress(ProgressPhaseCompleted, "")
+
+	return nil
+}
+
+// checkModifyExisting refuses to change provider versions if the management cluster already manages workload clusters.
+func (clusterAPI *Manager) checkModifyExisting(ctx context.Context) error {
+	if clusterAPI.version == "" {
+		return nil
+	}
+
+	providers, err := clusterAPI.listProviders(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(providers) == 0 {
+		return nil
+	}
+
+	clusters := &unstructured.UnstructuredList{}
+	clusters.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	if err = clusterAPI.runtimeClient.List(ctx, clusters, runtimeclient.Limit(1)); err != nil {
+		return err
+	}
+
+	if len(clusters.Items) == 0 {
+		return nil
+	}
+
+	for _, provider := range providers {
+		requested := clusterAPI.requestedVersion(provider.ProviderName, provider.GetProviderType())
+
+		if requested != "" && requested != provider.Version {
+			return fmt.Errorf(
+				"provider %s %s is installed, but %s is requested and the management cluster has workload clusters: "+
+					"use clusterctl upgrade to change provider versions or set AllowModifyExisting",
+				provider.InstanceName(), provider.Version, requested,
+			)
+		}
 	}
 
-	return clusterAPI.FetchState(ctx)
+	return nil
 }
 
 // InstallCore installs only core, global watched components (capi, cabpt, cacppt).
 func (clusterAPI *Manager) InstallCore(ctx context.Context, kubeconfig client.Kubeconfig) error {
-	installed, err := isCoreInstalled(ctx, clusterAPI.clientset)
+	inventory, err := clusterAPI.inventoryLabels(ctx)
 	if err != nil {
 		return err
 	}
 
-	if !installed {
+	// init is run only for the providers missing from the inventory, so an interrupted install is resumed
+	missing := func(providerType clusterctlv1.ProviderType, providers ...string) ([]string, error) {
+		res := []string{}
+
+		for _, provider := range providers {
+			if provider == "" {
+				continue
+			}
+
+			name, parseErr := parseProvider(provider)
+			if parseErr != nil {
+				return nil, parseErr
+			}
+
+			if _, ok := inventory[clusterctlv1.ManifestLabel(name, providerType)]; !ok {
+				res = append(res, provider)
+			}
+		}
+
+		return res, nil
+	}
+
+	missingCore, err := missing(clusterctlv1.CoreProviderType, clusterAPI.options.CoreProvider)
+	if err != nil {
+		return err
+	}
+
+	coreProvider := ""
+	if len(missingCore) > 0 {
+		coreProvider = clusterAPI.options.CoreProvider
+	}
+
+	bootstrapProviders, err := missing(clusterctlv1.BootstrapProviderType, clusterAPI.options.BootstrapProviders...)
+	if err != nil {
+		return err
+	}
+
+	controlPlaneProviders, err := missing(clusterctlv1.ControlPlaneProviderType, clusterAPI.options.ControlPlaneProviders...)
+	if err != nil {
+		return err
+	}
+
+	if coreProvider != "" || len(bootstrapProviders) > 0 || len(controlPlaneProviders) > 0 {
 		fmt.Println("initializing the core capi components")
 		// Initialize everything but the infra providers, as we want to specify target
 		// namespaces for those.
 		coreOpts := client.InitOptions{
 			Kubeconfig:              kubeconfig,
-			CoreProvider:            clusterAPI.options.CoreProvider,
-			BootstrapProviders:      clusterAPI.options.BootstrapProviders,
-			ControlPlaneProviders:   clusterAPI.options.ControlPlaneProviders,
+			CoreProvider:            coreProvider,
+			BootstrapProviders:      bootstrapProviders,
+			ControlPlaneProviders:   controlPlaneProviders,
 			InfrastructureProviders: []string{},
 			TargetNamespace:         "",
 			LogUsageInstructions:    false,
@@ -249,11 +650,19 @@ func (clusterAPI *Manager) InstallCore(ctx context.Context, kubeconfig client.Ku
 			coreOpts.WaitProviderTimeout = time.Minute * 5
 		}
 
+		if err = clusterAPI.checkImageDigests(coreOpts); err != nil {
+			return err
+		}
+
 		if _, err = clusterAPI.client.Init(coreOpts); err != nil {
 			return err
 		}
+
+		clusterAPI.recordEvent(corev1.EventTypeNormal, "ProviderInstalled", "installed core provider %s", clusterAPI.options.CoreProvider)
 	}
 
+	clusterAPI.progress(ProgressPhaseCoreInstalled, clusterAPI.options.CoreProvider)
+
 	return nil
 }
 
@@ -267,24 +676,53 @@ func (clusterAPI *Manager) InstallProvider(ctx context.Context, kubeconfig clien
 
 	providerString := provider.Name()
 
-	if provider.Version() != "" {
-		providerString += ":" + provider.Version()
+	if v := clusterAPI.requestedVersion(provider.Name(), clusterctlv1.InfrastructureProviderType); v != "" {
+		providerString += ":" + v
 	}
 
-	if installed, err = provider.IsInstalled(ctx, clusterAPI.clientset); err != nil {
+	inventory, err := clusterAPI.inventoryLabels(ctx)
+	if err != nil {
 		return err
 	}
 
+	_, installed = inventory[clusterctlv1.ManifestLabel(provider.Name(), clusterctlv1.InfrastructureProviderType)]
+
 	if !installed {
+		var deployed bool
+
+		if deployed, err = provider.IsInstalled(ctx, clusterAPI.clientset); err != nil {
+			return err
+		}
+
+		if deployed {
+			fmt.Printf("infrastructure provider %s is not recorded in the inventory, resuming the install\n", providerString)
+		}
 		fmt.Printf("initializing infrastructure provider %s\n", providerString)
 
-		vars, err := provider.ProviderVars()
-		if err != nil {
+		if preInstaller, ok := provider.(infrastructure.PreInstaller); ok {
+			var secretVars infrastructure.Variables
+
+			if secretVars, err = clusterAPI.credentialsSecretVars(ctx); err != nil {
+				return err
+			}
+
+			if err = preInstaller.PreInstall(secretVars); err != nil {
+				return err
+			}
+		}
+
+		var vars infrastructure.Variables
+
+		if vars, err = provider.ProviderVars(); err != nil {
 			return err
 		}
 
 		clusterAPI.patchConfig(vars)
 
+		if err = clusterAPI.applyCredentialsSecret(ctx); err != nil {
+			return err
+		}
+
 		infraOpts := client.InitOptions{
 			Kubeconfig:              kubeconfig,
 			CoreProvider:            "",
@@ -298,50 +736,103 @@ func (clusterAPI *Manager) InstallProvider(ctx context.Context, kubeconfig clien
 		if clusterAPI.options.WaitProviderTimeout != 0 {
 			infraOpts.WaitProviders = true
 			infraOpts.WaitProviderTimeout = time.Minute * 5
+
+			if timeout := infrastructure.ReadyTimeout(provider); timeout != 0 {
+				infraOpts.WaitProviderTimeout = timeout
+			}
+		}
+
+		if err = clusterAPI.checkImageDigests(infraOpts); err != nil {
+			return err
 		}
 
 		if _, err = clusterAPI.client.Init(infraOpts); err != nil {
 			return err
 		}
+
+		clusterAPI.recordEvent(corev1.EventTypeNormal, "ProviderInstalled", "installed infrastructure provider %s", providerString)
+	}
+
+	// clusterctl doesn't support watching namespace anymore, so the controller flag is reconciled directly,
+	// also for the providers installed before
+	label := clusterctlv1.ManifestLabel(provider.Name(), clusterctlv1.InfrastructureProviderType)
+
+	watchNamespace, err := clusterAPI.watchNamespace(ctx, label, provider.Namespace())
+	if err != nil {
+		return err
+	}
+
+	if watchNamespace != provider.WatchingNamespace() {
+		if err = clusterAPI.setWatchNamespace(ctx, label, provider.Namespace(), provider.WatchingNamespace()); err != nil {
+			return err
+		}
 	}
 
+	// provider secrets are rendered from the credentials secret, so they are not overwritten from the provider environment
+	if ensurer, ok := provider.(infrastructure.CredentialsEnsurer); ok && clusterAPI.options.CredentialsSecretRef == nil {
+		if err = ensurer.EnsureCredentials(ctx, clusterAPI.clientset); err != nil {
+			return fmt.Errorf("failed to ensure provider %s credentials %w", provider.Name(), err)
+		}
+	}
+
+	clusterAPI.progress(ProgressPhaseProviderInstalled, providerString)
+
 	return nil
 }
 
-// FetchState fetches infra providers and installed CAPI version if any.
-func (clusterAPI *Manager) FetchState(ctx context.Context) error {
-	resources, err := clusterAPI.clientset.ServerPreferredResources()
+// checkImageDigests verifies that all images used by the init are pinned by digest if RequireImageDigests is set.
+func (clusterAPI *Manager) checkImageDigests(opts client.InitOptions) error {
+	if !clusterAPI.options.RequireImageDigests {
+		return nil
+	}
+
+	images, err := clusterAPI.client.InitImages(opts)
 	if err != nil {
 		return err
 	}
 
-	gv := schema.GroupVersion{}
+	var floating []string
 
-	for _, list := range resources {
-		for _, resource := range list.APIResources {
-			if resource.Kind == "Provider" {
-				gv, err = schema.ParseGroupVersion(list.GroupVersion)
-
-				if err != nil {
-					return err
-				}
-			}
+	for _, image := range images {
+		if !strings.Contains(image, "@sha256:") {
+			floating = append(floating, image)
 		}
 	}
 
+	if len(floating) > 0 {
+		return fmt.Errorf("images are not pinned by digest: %s", strings.Join(floating, ", "))
+	}
+
+	return nil
+}
+
+// FetchState fetches infra providers and installed CAPI version if any.
+func (clusterAPI *Manager) FetchState(ctx context.Context) error {
+	gvk, err := clusterAPI.detectProviderGVK()
+	if err != nil {
+		return err
+	}
+
 	// Assume CAPI not installed
-	if gv.Version == "" {
+	if gvk.Version == "" {
 		return nil
 	}
 
 	providers := &unstructured.UnstructuredList{}
-	providers.SetGroupVersionKind(schema.GroupVersionKind{
-		Kind:    "Provider",
-		Group:   gv.Group,
-		Version: gv.Version,
-	})
+	providers.SetGroupVersionKind(gvk)
 
 	if err = clusterAPI.runtimeClient.List(ctx, providers); err != nil {
+		// pinned Provider kind is not served, assume CAPI not installed
+		if meta.IsNoMatchError(err) && clusterAPI.options.ProviderGVK.Kind != "" {
+			return nil
+		}
+
+		if clusterAPI.options.TolerateStateFetchErrors {
+			fmt.Printf("warning: failed to list providers, assuming no providers are installed: %s\n", err)
+
+			return nil
+		}
+
 		return fmt.Errorf("failed to list providers %w", err)
 	}
 
@@ -350,8 +841,18 @@ func (clusterAPI *Manager) FetchState(ctx context.Context) error {
 		providerVersion string
 		providerType    string
 		ok              bool
+		coreInstalled   bool
 	)
 
+	duplicates, err := findDuplicateProviders(providers.Items)
+	if err != nil {
+		return err
+	}
+
+	for _, duplicate := range duplicates {
+		fmt.Printf("warning: provider %s is installed more than once, controllers might conflict\n", duplicate)
+	}
+
 	infrastructureProviders := []infrastructure.Provider{}
 
 	for _, provider := range providers.Items {
@@ -361,6 +862,10 @@ func (clusterAPI *Manager) FetchState(ctx context.Context) error {
 			return fieldNotFound("type")
 		}
 
+		if clusterctlv1.ProviderType(providerType) == clusterctlv1.CoreProviderType {
+			coreInstalled = true
+		}
+
 		if clusterctlv1.ProviderType(providerType) == clusterctlv1.InfrastructureProviderType {
 			if providerName, ok, err = unstructured.NestedString(provider.Object, "providerName"); err != nil {
 				return err
@@ -374,7 +879,17 @@ func (clusterAPI *Manager) FetchState(ctx context.Context) error {
 				return fieldNotFound("providerVersion")
 			}
 
-			provider, err := infrastructure.NewProvider(fmt.Sprintf("%s:%s", providerName, providerVersion))
+			label := clusterctlv1.ManifestLabel(providerName, clusterctlv1.InfrastructureProviderType)
+
+			watchNamespace, err := clusterAPI.watchNamespace(ctx, label, provider.GetNamespace())
+			if err != nil {
+				fmt.Printf("warning: failed to read provider %s watch namespace: %s\n", label, err)
+			}
+
+			provider, err := infrastructure.NewProvider(fmt.Sprintf("%s:%s", providerName, providerVersion),
+				infrastructure.WithProviderNS(provider.GetNamespace()),
+				infrastructure.WithWatchingNS(watchNamespace),
+			)
 			// if we couldn't parse it then it's not supported
 			if err != nil {
 				continue
@@ -385,16 +900,183 @@ func (clusterAPI *Manager) FetchState(ctx context.Context) error {
 	}
 
 	clusterAPI.providers = infrastructureProviders
-	clusterAPI.version = gv.Version
+	clusterAPI.version = gvk.Version
+
+	if coreInstalled {
+		clusterAPI.checkFeatureGates(ctx)
+	}
 
 	return nil
 }
 
+// detectProviderGVK returns pinned Provider kind or looks it up via API discovery.
+//
+// Empty version is returned if Provider kind is not found.
+func (clusterAPI *Manager) detectProviderGVK() (schema.GroupVersionKind, error) {
+	if clusterAPI.options.ProviderGVK.Kind != "" {
+		return clusterAPI.options.ProviderGVK, nil
+	}
+
+	resources, err := clusterAPI.clientset.ServerPreferredResources()
+	if err != nil {
+		return schema.GroupVersionKind{}, err
+	}
+
+	gv := schema.GroupVersion{}
+
+	for _, list := range resources {
+		for _, resource := range list.APIResources {
+			if resource.Kind == "Provider" {
+				gv, err = schema.ParseGroupVersion(list.GroupVersion)
+
+				if err != nil {
+					return schema.GroupVersionKind{}, err
+				}
+			}
+		}
+	}
+
+	return gv.WithKind("Provider"), nil
+}
+
+// providerGVK returns the GroupVersionKind of clusterctl inventory Provider objects.
+func (clusterAPI *Manager) providerGVK() schema.GroupVersionKind {
+	if clusterAPI.options.ProviderGVK.Kind != "" {
+		return clusterAPI.options.ProviderGVK
+	}
+
+	return clusterctlv1.GroupVersion.WithKind("Provider")
+}
+
+// waitProviderReady waits for the infrastructure provider to become ready.
+//
+// Provider ReadyTimeout overrides the global WaitProviderTimeout.
+func (clusterAPI *Manager) waitProviderReady(ctx context.Context, provider infrastructure.Provider) error {
+	timeout := infrastructure.ReadyTimeout(provider)
+	if timeout == 0 {
+		timeout = clusterAPI.options.WaitProviderTimeout
+	}
+
+	if timeout != 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := provider.WaitReady(infrastructure.WithRetryableErrorFunc(ctx, clusterAPI.retryableErrorFunc()), clusterAPI.clientset); err != nil {
+		return fmt.Errorf("provider %s is not ready %w", provider.Name(), err)
+	}
+
+	return nil
+}
+
+// waitProviderCR waits until clusterctl inventory reports the infrastructure provider as installed.
+//
+// Controller deployment might be up before clusterctl finishes reconciling the install,
+// so the Provider object should exist and have the expected version. Provider objects have no conditions,
+// controller readiness is checked separately.
+func (clusterAPI *Manager) waitProviderCR(ctx context.Context, name, namespace, version string) error {
+	return retry.Constant(5*time.Minute, retry.WithUnits(5*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		providers := &unstructured.UnstructuredList{}
+		providers.SetGroupVersionKind(clusterAPI.providerGVK())
+
+		if err := clusterAPI.runtimeClient.List(ctx, providers, runtimeclient.InNamespace(namespace)); err != nil {
+			if meta.IsNoMatchError(err) {
+				return retry.ExpectedError(err)
+			}
+
+			return clusterAPI.retryable(err)
+		}
+
+		for _, provider := range providers.Items {
+			providerName, _, err := unstructured.NestedString(provider.Object, "providerName")
+			if err != nil {
+				return err
+			}
+
+			providerType, _, err := unstructured.NestedString(provider.Object, "type")
+			if err != nil {
+				return err
+			}
+
+			if providerName != name || clusterctlv1.ProviderType(providerType) != clusterctlv1.InfrastructureProviderType {
+				continue
+			}
+
+			providerVersion, _, err := unstructured.NestedString(provider.Object, "version")
+			if err != nil {
+				return err
+			}
+
+			if version != "" && providerVersion != version {
+				return retry.ExpectedError(fmt.Errorf("provider %s version is %s, expected %s", name, providerVersion, version))
+			}
+
+			return nil
+		}
+
+		return retry.ExpectedError(fmt.Errorf("provider %s is not found in namespace %s", name, namespace))
+	})
+}
+
+// DuplicateProviders returns clusterctl provider labels which are installed more than once.
+//
+// Duplicates usually indicate a failed upgrade which leaves two controllers reconciling the same objects.
+func (clusterAPI *Manager) DuplicateProviders(ctx context.Context) ([]string, error) {
+	providers := &unstructured.UnstructuredList{}
+	providers.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err := clusterAPI.runtimeClient.List(ctx, providers); err != nil {
+		return nil, fmt.Errorf("failed to list providers %w", err)
+	}
+
+	return findDuplicateProviders(providers.Items)
+}
+
+func findDuplicateProviders(providers []unstructured.Unstructured) ([]string, error) {
+	counts := map[string]int{}
+
+	for _, provider := range providers {
+		providerName, _, err := unstructured.NestedString(provider.Object, "providerName")
+		if err != nil {
+			return nil, err
+		}
+
+		providerType, _, err := unstructured.NestedString(provider.Object, "type")
+		if err != nil {
+			return nil, err
+		}
+
+		counts[clusterctlv1.ManifestLabel(providerName, clusterctlv1.ProviderType(providerType))]++
+	}
+
+	duplicates := []string{}
+
+	for label, count := range counts {
+		if count > 1 {
+			duplicates = append(duplicates, label)
+		}
+	}
+
+	sort.Strings(duplicates)
+
+	return duplicates, nil
+}
+
 // Version returns installed CAPI version.
 func (clusterAPI *Manager) Version() string {
 	return clusterAPI.version
 }
 
+func (clusterAPI *Manager) recordEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if clusterAPI.options.EventRecorder == nil || clusterAPI.options.EventObject == nil {
+		return
+	}
+
+	clusterAPI.options.EventRecorder.Eventf(clusterAPI.options.EventObject, eventType, reason, messageFmt, args...)
+}
+
 func (clusterAPI *Manager) patchConfig(vars infrastructure.Variables) {
 	for key, value := range vars {
 		if value != "" {
@@ -465,15 +1147,38 @@ func fieldNotFound(fields ...string) error {
 	return fmt.Errorf("failed to find field %s", strings.Join(fields, "."))
 }
 
-func isCoreInstalled(ctx context.Context, clientset *kubernetes.Clientset) (bool, error) {
-	_, err := clientset.CoreV1().Namespaces().Get(ctx, constants.CoreCAPINamespace, metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return false, nil
+// inventoryLabels returns clusterctl labels of the providers recorded in the clusterctl inventory.
+//
+// clusterctl records the provider in the inventory after applying its components, so the provider
+// which is missing from the inventory is either not installed or its install was interrupted,
+// and clusterctl init can be run for it again.
+func (clusterAPI *Manager) inventoryLabels(ctx context.Context) (map[string]struct{}, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err := clusterAPI.runtimeClient.List(ctx, list); err != nil {
+		if meta.IsNoMatchError(err) {
+			return map[string]struct{}{}, nil
 		}
 
-		return false, err
+		return nil, fmt.Errorf("failed to list providers %w", err)
 	}
 
-	return true, nil
+	res := make(map[string]struct{}, len(list.Items))
+
+	for _, item := range list.Items {
+		name, _, err := unstructured.NestedString(item.Object, "providerName")
+		if err != nil {
+			return nil, err
+		}
+
+		providerType, _, err := unstructured.NestedString(item.Object, "type")
+		if err != nil {
+			return nil, err
+		}
+
+		res[clusterctlv1.ManifestLabel(name, clusterctlv1.ProviderType(providerType))] = struct{}{}
+	}
+
+	return res, nil
 }
@@ -0,0 +1,136 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// initRecorder is the clusterctl client which records Init calls, other methods are not implemented.
+type initRecorder struct {
+	client.Client
+
+	calls []client.InitOptions
+}
+
+func (c *initRecorder) Init(options client.InitOptions) ([]client.Components, error) {
+	c.calls = append(c.calls, options)
+
+	return nil, nil
+}
+
+func inventoryProvider(namespace, name string, providerType clusterctlv1.ProviderType) *clusterctlv1.Provider {
+	return &clusterctlv1.Provider{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      clusterctlv1.ManifestLabel(name, providerType),
+		},
+		ProviderName: name,
+		Type:         string(providerType),
+		Version:      "v1.1.3",
+	}
+}
+
+func newInventoryManager(t *testing.T, providers ...runtimeclient.Object) (*Manager, *initRecorder) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+
+	if err := clusterctlv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &initRecorder{}
+
+	return &Manager{
+		client:        recorder,
+		runtimeClient: fake.NewClientBuilder().WithScheme(scheme).WithObjects(providers...).Build(),
+		options: Options{
+			CoreProvider:          "cluster-api:v1.1.3",
+			BootstrapProviders:    []string{"talos:v0.5.3"},
+			ControlPlaneProviders: []string{"talos:v0.4.5"},
+		},
+	}, recorder
+}
+
+// TestInstallCoreResumesInitializedInventory simulates an install interrupted after clusterctl init recorded the providers,
+// but before the controllers became ready: re-running the install shouldn't initialize them again.
+func TestInstallCoreResumesInitializedInventory(t *testing.T) {
+	clusterAPI, recorder := newInventoryManager(t,
+		inventoryProvider("capi-system", "cluster-api", clusterctlv1.CoreProviderType),
+		inventoryProvider("cabpt-system", "talos", clusterctlv1.BootstrapProviderType),
+		inventoryProvider("cacppt-system", "talos", clusterctlv1.ControlPlaneProviderType),
+	)
+
+	if err := clusterAPI.InstallCore(context.Background(), client.Kubeconfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.calls) != 0 {
+		t.Fatalf("expected no init calls, got %+v", recorder.calls)
+	}
+}
+
+// TestInstallCoreInitializesMissingProviders checks that only the providers missing from the inventory are initialized.
+func TestInstallCoreInitializesMissingProviders(t *testing.T) {
+	clusterAPI, recorder := newInventoryManager(t,
+		inventoryProvider("capi-system", "cluster-api", clusterctlv1.CoreProviderType),
+	)
+
+	if err := clusterAPI.InstallCore(context.Background(), client.Kubeconfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.calls) != 1 {
+		t.Fatalf("expected a single init call, got %d", len(recorder.calls))
+	}
+
+	call := recorder.calls[0]
+
+	if call.CoreProvider != "" {
+		t.Errorf("core provider is already installed, but init got %q", call.CoreProvider)
+	}
+
+	if !reflect.DeepEqual(call.BootstrapProviders, []string{"talos:v0.5.3"}) {
+		t.Errorf("unexpected bootstrap providers %v", call.BootstrapProviders)
+	}
+
+	if !reflect.DeepEqual(call.ControlPlaneProviders, []string{"talos:v0.4.5"}) {
+		t.Errorf("unexpected control plane providers %v", call.ControlPlaneProviders)
+	}
+}
+
+// TestInstalledProviderVersion checks that the installed version is read from the inventory, not from the requested providers.
+func TestInstalledProviderVersion(t *testing.T) {
+	aws := inventoryProvider("capa-system", "aws", clusterctlv1.InfrastructureProviderType)
+	aws.Version = "v1.2.0"
+
+	clusterAPI, _ := newInventoryManager(t,
+		inventoryProvider("capi-system", "cluster-api", clusterctlv1.CoreProviderType),
+		aws,
+	)
+
+	version, err := clusterAPI.installedProviderVersion(context.Background(), "aws", clusterctlv1.InfrastructureProviderType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version != "v1.2.0" {
+		t.Errorf("expected v1.2.0, got %q", version)
+	}
+
+	if _, err = clusterAPI.installedProviderVersion(context.Background(), "gcp", clusterctlv1.InfrastructureProviderType); err == nil {
+		t.Error("expected an error for the provider missing from the inventory")
+	}
+}
@@ -0,0 +1,100 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const certManagerWebhookService = "cert-manager-webhook"
+
+// certManagerNamespace returns the configured cert-manager namespace.
+func (clusterAPI *Manager) certManagerNamespace() string {
+	if clusterAPI.options.CertManagerNamespace != "" {
+		return clusterAPI.options.CertManagerNamespace
+	}
+
+	return constants.CertManagerNamespace
+}
+
+// checkCertManagerNamespace verifies that custom cert-manager namespace exists.
+func (clusterAPI *Manager) checkCertManagerNamespace(ctx context.Context) error {
+	namespace := clusterAPI.certManagerNamespace()
+	if namespace == constants.CertManagerNamespace {
+		return nil
+	}
+
+	if _, err := clusterAPI.clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("cert-manager namespace %s doesn't exist, cert-manager should be preinstalled into the custom namespace", namespace)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// waitCertManagerWebhook waits until cert-manager webhook has endpoints and admits requests.
+//
+// Webhook is checked by a dry-run creation of a self-signed Issuer.
+func (clusterAPI *Manager) waitCertManagerWebhook(ctx context.Context) error {
+	namespace := clusterAPI.certManagerNamespace()
+
+	return retry.Constant(5*time.Minute, retry.WithUnits(5*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		endpoints, err := clusterAPI.clientset.CoreV1().Endpoints(namespace).Get(ctx, certManagerWebhookService, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return retry.ExpectedError(err)
+			}
+
+			return clusterAPI.retryable(err)
+		}
+
+		ready := false
+
+		for _, subset := range endpoints.Subsets {
+			if len(subset.Addresses) > 0 {
+				ready = true
+
+				break
+			}
+		}
+
+		if !ready {
+			return retry.ExpectedError(fmt.Errorf("cert-manager webhook has no ready endpoints"))
+		}
+
+		issuer := &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "cert-manager.io/v1",
+				"kind":       "Issuer",
+				"metadata": map[string]interface{}{
+					"name":      "capi-utils-webhook-check",
+					"namespace": namespace,
+				},
+				"spec": map[string]interface{}{
+					"selfSigned": map[string]interface{}{},
+				},
+			},
+		}
+
+		if err = clusterAPI.runtimeClient.Create(ctx, issuer, runtimeclient.DryRunAll); err != nil {
+			return retry.ExpectedError(fmt.Errorf("cert-manager webhook is not serving %w", err))
+		}
+
+		return nil
+	})
+}
@@ -7,13 +7,27 @@ package capi
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/talos-systems/go-retry/retry"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+// EtcdClusterHealthyCondition is set on the control plane object once etcd cluster is healthy.
+const EtcdClusterHealthyCondition clusterv1.ConditionType = "EtcdClusterHealthy"
+
+// Condition is a CAPI object status condition.
+type Condition struct {
+	Type    clusterv1.ConditionType
+	Status  corev1.ConditionStatus
+	Reason  string
+	Message string
+}
+
 // CheckClusterReady verifies that cluster ready from the CAPI point of view.
 //nolint:cyclop,gocyclo,gocognit
 func (clusterAPI *Manager) CheckClusterReady(ctx context.Context, cluster *Cluster) error {
@@ -149,3 +163,161 @@ func checkReplicasReady(in unstructured.Unstructured) error {
 
 	return nil
 }
+
+// WaitForEtcdHealthy waits until the control plane object reports etcd cluster as healthy.
+func (cluster *Cluster) WaitForEtcdHealthy(ctx context.Context) error {
+	return retry.Constant(10*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		controlPlane, err := cluster.ControlPlanes(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		conditions, err := getConditions(controlPlane.Object)
+		if err != nil {
+			return err
+		}
+
+		for _, cond := range conditions {
+			if cond.Type != EtcdClusterHealthyCondition {
+				continue
+			}
+
+			if cond.Status != corev1.ConditionTrue {
+				return retry.ExpectedError(fmt.Errorf("etcd cluster is not healthy: %s %s", cond.Reason, cond.Message))
+			}
+
+			return nil
+		}
+
+		return retry.ExpectedError(fmt.Errorf("%s %s has no %s condition", controlPlane.GetKind(), controlPlane.GetName(), EtcdClusterHealthyCondition))
+	})
+}
+
+// WaitForInfrastructure waits until the infrastructure cluster object (e.g. AWSCluster) reports Ready condition.
+//
+// Infrastructure objects without conditions are checked with status.ready, failure message of the object is returned as is.
+func (cluster *Cluster) WaitForInfrastructure(ctx context.Context) error {
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		if err := cluster.sync(ctx); err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		infrastructureRef, err := getRef(cluster.cluster.Object, "spec", "infrastructureRef")
+		if err != nil {
+			return retry.ExpectedError(err)
+		}
+
+		var infrastructure unstructured.Unstructured
+
+		infrastructure.SetGroupVersionKind(infrastructureRef.gvk)
+
+		if err = cluster.manager.runtimeClient.Get(ctx, infrastructureRef.NamespacedName, &infrastructure); err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		failureMessage, _, err := unstructured.NestedString(infrastructure.Object, "status", "failureMessage")
+		if err != nil {
+			return err
+		}
+
+		if failureMessage != "" {
+			return fmt.Errorf("%s %s failed: %s", infrastructure.GetKind(), infrastructure.GetName(), failureMessage)
+		}
+
+		conditions, err := getConditions(infrastructure.Object)
+		if err != nil {
+			return err
+		}
+
+		for _, cond := range conditions {
+			if cond.Type != clusterv1.ReadyCondition {
+				continue
+			}
+
+			if cond.Status != corev1.ConditionTrue {
+				return retry.ExpectedError(fmt.Errorf("%s %s is not ready: %s %s", infrastructure.GetKind(), infrastructure.GetName(), cond.Reason, cond.Message))
+			}
+
+			return nil
+		}
+
+		ready, _, err := unstructured.NestedBool(infrastructure.Object, "status", "ready")
+		if err != nil {
+			return err
+		}
+
+		if !ready {
+			return retry.ExpectedError(fmt.Errorf("%s %s is not ready", infrastructure.GetKind(), infrastructure.GetName()))
+		}
+
+		return nil
+	})
+}
+
+// ObjectConditions returns status conditions of any CAPI object.
+//
+// Namespace should be empty for cluster-scoped objects.
+func (clusterAPI *Manager) ObjectConditions(ctx context.Context, gvk schema.GroupVersionKind, name, namespace string) ([]Condition, error) {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+
+	if err := clusterAPI.runtimeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, object); err != nil {
+		return nil, err
+	}
+
+	return getConditions(object.Object)
+}
+
+func getConditions(object map[string]interface{}) ([]Condition, error) {
+	list, found, err := unstructured.NestedSlice(object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	conditions := make([]Condition, 0, len(list))
+
+	for _, cond := range list {
+		c, ok := cond.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("failed to convert condition to map[string]interface{}")
+		}
+
+		var (
+			res    Condition
+			t      string
+			status string
+		)
+
+		if t, found, err = unstructured.NestedString(c, "type"); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, fieldNotFound("type")
+		}
+
+		res.Type = clusterv1.ConditionType(t)
+
+		if status, found, err = unstructured.NestedString(c, "status"); err != nil {
+			return nil, err
+		} else if !found {
+			return nil, fieldNotFound("status")
+		}
+
+		res.Status = corev1.ConditionStatus(status)
+
+		if res.Reason, _, err = unstructured.NestedString(c, "reason"); err != nil {
+			return nil, err
+		}
+
+		if res.Message, _, err = unstructured.NestedString(c, "message"); err != nil {
+			return nil, err
+		}
+
+		conditions = append(conditions, res)
+	}
+
+	return conditions, nil
+}
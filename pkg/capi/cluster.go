@@ -25,7 +25,6 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	clientcmd "k8s.io/client-go/tools/clientcmd"
-	capiclient "sigs.k8s.io/cluster-api/cmd/clusterctl/client"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -127,23 +126,12 @@ func (cluster *Cluster) Sync(ctx context.Context) error {
 		return err
 	}
 
-	kubeconfig, err := cluster.manager.GetKubeconfig(ctx)
+	raw, err := cluster.GetWorkloadKubeconfig(ctx)
 	if err != nil {
 		return err
 	}
 
-	options := capiclient.GetKubeconfigOptions{
-		Kubeconfig:          kubeconfig,
-		WorkloadClusterName: cluster.name,
-		Namespace:           cluster.namespace,
-	}
-
-	raw, err := cluster.manager.client.GetKubeconfig(options)
-	if err != nil {
-		return err
-	}
-
-	config, err := clientcmd.RESTConfigFromKubeConfig([]byte(raw))
+	config, err := clientcmd.RESTConfigFromKubeConfig(raw)
 	if err != nil {
 		return err
 	}
@@ -291,6 +279,27 @@ func (cluster *Cluster) ControlPlanes(ctx context.Context) (*unstructured.Unstru
 	return &controlPlane, nil
 }
 
+// KubernetesVersion returns Kubernetes version actually running on the control plane.
+//
+// It might differ from the version in the control plane spec while the upgrade is in progress.
+func (cluster *Cluster) KubernetesVersion(ctx context.Context) (string, error) {
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	version, found, err := unstructured.NestedString(controlPlane.Object, "status", "version")
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		return "", fieldNotFound("status", "version")
+	}
+
+	return version, nil
+}
+
 // Workers gets MachineDeployment list from the management cluster.
 func (cluster *Cluster) Workers(ctx context.Context) (*unstructured.UnstructuredList, error) {
 	var machineDeployments unstructured.UnstructuredList
@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cniDaemonSets are the kube-system daemonsets of the well-known CNI plugins.
+var cniDaemonSets = []string{"kube-flannel", "calico-node", "cilium", "canal", "weave-net", "kube-router"}
+
+// CNIStatus is the CNI plugin daemonset status in the workload cluster.
+type CNIStatus struct {
+	// DaemonSet is the name of the CNI daemonset in kube-system namespace.
+	DaemonSet string
+
+	// Nodes is the number of the workload cluster nodes.
+	Nodes int
+
+	Desired   int32
+	Ready     int32
+	Available int32
+	Updated   int32
+}
+
+// Healthy returns true if the CNI pod is ready on every node scheduled to run it and the rollout is done.
+func (status *CNIStatus) Healthy() bool {
+	return status.Desired > 0 && status.Ready == status.Desired && status.Available == status.Desired && status.Updated == status.Desired
+}
+
+// String implements fmt.Stringer.
+func (status *CNIStatus) String() string {
+	return fmt.Sprintf("%s: %d/%d pods ready on %d nodes", status.DaemonSet, status.Ready, status.Desired, status.Nodes)
+}
+
+// CNIStatus reports the status of the CNI plugin daemonset of the workload cluster.
+//
+// CNI daemonset is detected by the well-known names in kube-system namespace: flannel, calico, cilium, canal, weave and kube-router.
+func (cluster *Cluster) CNIStatus(ctx context.Context) (*CNIStatus, error) {
+	clientset, err := cluster.workloadClientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range cniDaemonSets {
+		var daemonSet *appsv1.DaemonSet
+
+		daemonSet, err = clientset.AppsV1().DaemonSets("kube-system").Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		return &CNIStatus{
+			DaemonSet: name,
+			Nodes:     len(nodes.Items),
+			Desired:   daemonSet.Status.DesiredNumberScheduled,
+			Ready:     daemonSet.Status.NumberReady,
+			Available: daemonSet.Status.NumberAvailable,
+			Updated:   daemonSet.Status.UpdatedNumberScheduled,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no CNI daemonset found in kube-system namespace, expected one of %s", strings.Join(cniDaemonSets, ", "))
+}
@@ -0,0 +1,267 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// clusterNamePlaceholder replaces the cluster name in the compared object names and values.
+	clusterNamePlaceholder = "<cluster>"
+
+	// namespacePlaceholder replaces the cluster namespace in the compared namespace fields.
+	namespacePlaceholder = "<namespace>"
+)
+
+// ClusterDiff is the result of CompareClusters.
+//
+// Objects are identified as Kind/name, the cluster name in the object names is replaced with <cluster>.
+type ClusterDiff struct {
+	// OnlyInA lists objects which exist only in the first cluster.
+	OnlyInA []string
+
+	// OnlyInB lists objects which exist only in the second cluster.
+	OnlyInB []string
+
+	// Fields lists field differences of the objects which exist in both clusters.
+	Fields []FieldDiff
+}
+
+// FieldDiff is a field difference of the object, nil value means that the field is not set.
+type FieldDiff struct {
+	Object string
+	Path   string
+	A      interface{}
+	B      interface{}
+}
+
+// Empty returns true if the clusters match.
+func (diff *ClusterDiff) Empty() bool {
+	return len(diff.OnlyInA) == 0 && len(diff.OnlyInB) == 0 && len(diff.Fields) == 0
+}
+
+// String implements fmt.Stringer.
+func (diff *ClusterDiff) String() string {
+	var sb strings.Builder
+
+	for _, obj := range diff.OnlyInA {
+		fmt.Fprintf(&sb, "- %s\n", obj)
+	}
+
+	for _, obj := range diff.OnlyInB {
+		fmt.Fprintf(&sb, "+ %s\n", obj)
+	}
+
+	for _, field := range diff.Fields {
+		fmt.Fprintf(&sb, "~ %s %s: %s -> %s\n", field.Object, field.Path, formatFieldValue(field.A), formatFieldValue(field.B))
+	}
+
+	return sb.String()
+}
+
+// CompareClusters diffs the specs of two cluster object graphs, e.g. to verify that a templated cluster matches the reference one.
+//
+// Compared objects are the Cluster and the objects which are not managed by other objects of the graph: control plane,
+// infrastructure cluster, MachineDeployments and templates. Machines, MachineSets and other objects created
+// by the controllers are skipped. Status and server-populated metadata are ignored, the cluster name is
+// normalized in the names, keys and values equal to the cluster name or prefixed with it, and the cluster namespace
+// is normalized in the namespace fields (e.g. infrastructureRef.namespace), so clusters in different namespaces can be compared.
+func (clusterAPI *Manager) CompareClusters(ctx context.Context, a, b types.NamespacedName) (*ClusterDiff, error) {
+	objectsA, err := clusterAPI.comparableObjects(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	objectsB, err := clusterAPI.comparableObjects(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &ClusterDiff{}
+
+	for key, objA := range objectsA {
+		objB, ok := objectsB[key]
+		if !ok {
+			diff.OnlyInA = append(diff.OnlyInA, key)
+
+			continue
+		}
+
+		fieldsA := map[string]interface{}{}
+		fieldsB := map[string]interface{}{}
+
+		flattenFields("", objA, fieldsA)
+		flattenFields("", objB, fieldsB)
+
+		for path, valueA := range fieldsA {
+			if valueB := fieldsB[path]; !reflect.DeepEqual(valueA, valueB) {
+				diff.Fields = append(diff.Fields, FieldDiff{Object: key, Path: path, A: valueA, B: valueB})
+			}
+		}
+
+		for path, valueB := range fieldsB {
+			if _, ok := fieldsA[path]; !ok {
+				diff.Fields = append(diff.Fields, FieldDiff{Object: key, Path: path, B: valueB})
+			}
+		}
+	}
+
+	for key := range objectsB {
+		if _, ok := objectsA[key]; !ok {
+			diff.OnlyInB = append(diff.OnlyInB, key)
+		}
+	}
+
+	sort.Strings(diff.OnlyInA)
+	sort.Strings(diff.OnlyInB)
+	sort.Slice(diff.Fields, func(i, j int) bool {
+		if diff.Fields[i].Object != diff.Fields[j].Object {
+			return diff.Fields[i].Object < diff.Fields[j].Object
+		}
+
+		return diff.Fields[i].Path < diff.Fields[j].Path
+	})
+
+	return diff, nil
+}
+
+// comparableObjects returns normalized top level objects of the cluster graph mapped by Kind/name.
+func (clusterAPI *Manager) comparableObjects(ctx context.Context, name types.NamespacedName) (map[string]map[string]interface{}, error) {
+	cluster, err := clusterAPI.NewCluster(ctx, name.Name, name.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := cluster.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]map[string]interface{}{}
+	clusterName := types.NamespacedName{Namespace: cluster.namespace, Name: cluster.name}
+
+	for i := range objects {
+		obj := &objects[i]
+
+		if controller := metav1.GetControllerOf(obj); controller != nil && controller.Kind != "Cluster" {
+			continue
+		}
+
+		spec := map[string]interface{}{}
+
+		for key, value := range obj.Object {
+			switch key {
+			case "apiVersion", "kind", "metadata", "status":
+			default:
+				spec[key] = normalizeClusterName(value, clusterName)
+			}
+		}
+
+		if labels := obj.GetLabels(); len(labels) > 0 {
+			spec["labels"] = normalizeClusterName(stringMap(labels), clusterName)
+		}
+
+		annotations := obj.GetAnnotations()
+		delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+
+		if len(annotations) > 0 {
+			spec["annotations"] = normalizeClusterName(stringMap(annotations), clusterName)
+		}
+
+		res[fmt.Sprintf("%s/%s", obj.GetKind(), normalizeClusterName(obj.GetName(), clusterName))] = spec
+	}
+
+	return res, nil
+}
+
+// normalizeClusterName replaces the cluster name in the strings and map keys equal to it or prefixed with it,
+// and the cluster namespace in the namespace fields.
+func normalizeClusterName(value interface{}, cluster types.NamespacedName) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == cluster.Name || strings.HasPrefix(v, cluster.Name+"-") {
+			return clusterNamePlaceholder + strings.TrimPrefix(v, cluster.Name)
+		}
+
+		return v
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+
+		for key, item := range v {
+			if namespace, ok := item.(string); ok && key == "namespace" && namespace == cluster.Namespace {
+				res[key] = namespacePlaceholder
+
+				continue
+			}
+
+			res[normalizeClusterName(key, cluster).(string)] = normalizeClusterName(item, cluster)
+		}
+
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(v))
+
+		for i, item := range v {
+			res[i] = normalizeClusterName(item, cluster)
+		}
+
+		return res
+	default:
+		return v
+	}
+}
+
+// flattenFields maps the leaf field paths to the values, e.g. spec.topology.workers.machineDeployments[0].replicas.
+func flattenFields(prefix string, value interface{}, res map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+
+			flattenFields(path, item, res)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flattenFields(fmt.Sprintf("%s[%d]", prefix, i), item, res)
+		}
+	default:
+		res[prefix] = v
+	}
+}
+
+func stringMap(m map[string]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(m))
+
+	for key, value := range m {
+		res[key] = value
+	}
+
+	return res
+}
+
+func formatFieldValue(value interface{}) string {
+	if value == nil {
+		return "<unset>"
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
@@ -0,0 +1,90 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestNormalizeClusterName(t *testing.T) {
+	cluster := types.NamespacedName{Namespace: "team-a", Name: "prod"}
+
+	for _, tt := range []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{
+			name:     "name",
+			value:    "prod",
+			expected: "<cluster>",
+		},
+		{
+			name:     "prefixed",
+			value:    "prod-md-0",
+			expected: "<cluster>-md-0",
+		},
+		{
+			name:     "lookalike",
+			value:    "production",
+			expected: "production",
+		},
+		{
+			name:  "ref",
+			value: map[string]interface{}{"kind": "MetalCluster", "name": "prod", "namespace": "team-a"},
+			expected: map[string]interface{}{
+				"kind": "MetalCluster", "name": "<cluster>", "namespace": "<namespace>",
+			},
+		},
+		{
+			name:     "namespace value outside of the namespace field",
+			value:    map[string]interface{}{"description": "team-a", "namespace": "team-b"},
+			expected: map[string]interface{}{"description": "team-a", "namespace": "team-b"},
+		},
+		{
+			name:     "keys",
+			value:    map[string]interface{}{"prod-pool": []interface{}{"prod", int64(3)}},
+			expected: map[string]interface{}{"<cluster>-pool": []interface{}{"<cluster>", int64(3)}},
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if res := normalizeClusterName(tt.value, cluster); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
+
+func TestFlattenFields(t *testing.T) {
+	res := map[string]interface{}{}
+
+	flattenFields("", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": int64(3),
+			"template": map[string]interface{}{
+				"configPatches": []interface{}{
+					map[string]interface{}{"op": "add", "path": "/machine/install/disk"},
+				},
+			},
+			"paused": nil,
+		},
+	}, res)
+
+	expected := map[string]interface{}{
+		"spec.replicas":                       int64(3),
+		"spec.template.configPatches[0].op":   "add",
+		"spec.template.configPatches[0].path": "/machine/install/disk",
+		"spec.paused":                         nil,
+	}
+
+	if !reflect.DeepEqual(res, expected) {
+		t.Errorf("expected %v, got %v", expected, res)
+	}
+}
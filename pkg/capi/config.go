@@ -6,6 +6,7 @@
 package capi
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -100,6 +101,13 @@ func (c *Config) Init(path string) error {
 	return c.config.ReadInConfig()
 }
 
+// InitFromBytes initializes the config from the in-memory clusterctl config contents.
+func (c *Config) InitFromBytes(data []byte) error {
+	c.config.SetConfigType("yaml")
+
+	return c.config.ReadConfig(bytes.NewReader(data))
+}
+
 // Get implements config.Reader.
 func (c *Config) Get(key string) (string, error) {
 	if c.config.Get(key) == nil {
@@ -114,6 +122,24 @@ func (c *Config) Set(key, value string) {
 	c.config.Set(key, value)
 }
 
+// setTemporary sets the variables and returns a func which restores their previous values.
+func (c *Config) setTemporary(vars map[string]string) func() {
+	previous := make(map[string]interface{}, len(vars))
+
+	for key, value := range vars {
+		previous[key] = c.config.Get(key)
+
+		c.config.Set(key, value)
+	}
+
+	return func() {
+		// nil override makes viper fall back to the env and the config file
+		for key, value := range previous {
+			c.config.Set(key, value)
+		}
+	}
+}
+
 // UnmarshalKey implements config.Reader.
 func (c *Config) UnmarshalKey(key string, rawval interface{}) error {
 	return c.config.UnmarshalKey(key, rawval)
@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import "testing"
+
+func TestConfigSetTemporary(t *testing.T) {
+	t.Setenv("CAPI_UTILS_TEST_FROM_ENV", "env")
+
+	cfg := newConfig()
+
+	if err := cfg.InitFromBytes([]byte("CAPI_UTILS_TEST_FROM_FILE: file\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Set("CAPI_UTILS_TEST_SET", "set")
+
+	restore := cfg.setTemporary(map[string]string{
+		"CAPI_UTILS_TEST_FROM_ENV":  "temporary",
+		"CAPI_UTILS_TEST_FROM_FILE": "temporary",
+		"CAPI_UTILS_TEST_SET":       "temporary",
+		"CAPI_UTILS_TEST_UNSET":     "temporary",
+	})
+
+	for _, key := range []string{"CAPI_UTILS_TEST_FROM_ENV", "CAPI_UTILS_TEST_FROM_FILE", "CAPI_UTILS_TEST_SET", "CAPI_UTILS_TEST_UNSET"} {
+		if value, err := cfg.Get(key); err != nil || value != "temporary" {
+			t.Errorf("expected %s to be temporary, got %q %v", key, value, err)
+		}
+	}
+
+	restore()
+
+	for key, expected := range map[string]string{
+		"CAPI_UTILS_TEST_FROM_ENV":  "env",
+		"CAPI_UTILS_TEST_FROM_FILE": "file",
+		"CAPI_UTILS_TEST_SET":       "set",
+	} {
+		if value, err := cfg.Get(key); err != nil || value != expected {
+			t.Errorf("expected %s to be restored to %q, got %q %v", key, expected, value, err)
+		}
+	}
+
+	if value, err := cfg.Get("CAPI_UTILS_TEST_UNSET"); err == nil {
+		t.Errorf("expected CAPI_UTILS_TEST_UNSET to be unset, got %q", value)
+	}
+}
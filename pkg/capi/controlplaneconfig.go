@@ -0,0 +1,325 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/talos-systems/go-retry/retry"
+	"github.com/talos-systems/talos/pkg/machinery/config/configloader"
+	"github.com/talos-systems/talos/pkg/machinery/config/configpatcher"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+// talosConfigSections are the top level keys of Talos v1alpha1 machine config.
+var talosConfigSections = []string{"version", "debug", "persist", "machine", "cluster"}
+
+// PatchTalosControlPlaneConfig appends JSON 6902 patch (in JSON or YAML form) to the TalosControlPlane config patches
+// and rolls out control plane machines, as the patches are applied to the machine config only on machine creation.
+//
+// The patch is applied to the machine config of a current control plane machine and the result is decoded strictly,
+// so the unknown keys and mistyped values are rejected before the rollout. Configs of Talos versions newer than
+// the vendored config schema are only checked to accept the patch. Semantic validation of the patched config
+// (e.g. that the values are consistent) is done by Talos when the new machines boot.
+// The previous infrastructure template is deleted after the rollout.
+func (cluster *Cluster) PatchTalosControlPlaneConfig(ctx context.Context, patch []byte) error {
+	configPatches, err := decodeTalosConfigPatch(patch)
+	if err != nil {
+		return err
+	}
+
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return err
+	}
+
+	if controlPlane.GetKind() != "TalosControlPlane" {
+		return fmt.Errorf("control plane %s is %s, Talos config patches are supported only for TalosControlPlane", controlPlane.GetName(), controlPlane.GetKind())
+	}
+
+	if err = cluster.validateTalosConfigPatch(ctx, configPatches); err != nil {
+		return err
+	}
+
+	for _, config := range []string{"init", "controlplane"} {
+		var (
+			found   bool
+			patches []interface{}
+		)
+
+		path := []string{"spec", "controlPlaneConfig", config}
+
+		if _, found, err = unstructured.NestedMap(controlPlane.Object, path...); err != nil {
+			return err
+		}
+
+		if !found {
+			continue
+		}
+
+		if patches, _, err = unstructured.NestedSlice(controlPlane.Object, append(path, "configPatches")...); err != nil {
+			return err
+		}
+
+		if err = unstructured.SetNestedSlice(controlPlane.Object, append(patches, configPatches...), append(path, "configPatches")...); err != nil {
+			return err
+		}
+	}
+
+	previousTemplate, err := getRef(controlPlane.Object, "spec", "infrastructureTemplate")
+	if err != nil {
+		return err
+	}
+
+	// control plane provider creates new machines only when the infrastructure template changes
+	template, err := cluster.copyTemplate(ctx, controlPlane, fmt.Sprintf("%s-%s", controlPlane.GetName(), utilrand.String(5)),
+		func(*unstructured.Unstructured) error { return nil }, "spec", "infrastructureTemplate")
+	if err != nil {
+		return err
+	}
+
+	if err = unstructured.SetNestedField(controlPlane.Object, template.GetName(), "spec", "infrastructureTemplate", "name"); err != nil {
+		return err
+	}
+
+	outdated, err := cluster.controlPlaneMachineUIDs(ctx)
+	if err != nil {
+		cluster.manager.runtimeClient.Delete(ctx, template) //nolint:errcheck
+
+		return err
+	}
+
+	if err = cluster.manager.runtimeClient.Update(ctx, controlPlane); err != nil {
+		cluster.manager.runtimeClient.Delete(ctx, template) //nolint:errcheck
+
+		return err
+	}
+
+	fmt.Printf("rolling out control plane %s with the patched config\n", controlPlane.GetName())
+
+	if err = cluster.waitControlPlaneRollout(ctx, outdated); err != nil {
+		return err
+	}
+
+	previous := &unstructured.Unstructured{}
+	previous.SetGroupVersionKind(previousTemplate.gvk)
+	previous.SetName(previousTemplate.Name)
+	previous.SetNamespace(previousTemplate.Namespace)
+
+	if err = cluster.manager.runtimeClient.Delete(ctx, previous); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete previous infrastructure template %s %w", previousTemplate.Name, err)
+	}
+
+	return nil
+}
+
+// decodeTalosConfigPatch decodes and validates the patch, returning operations in the configPatches format.
+func decodeTalosConfigPatch(patch []byte) ([]interface{}, error) {
+	data, err := yaml.YAMLToJSON(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config patch %w", err)
+	}
+
+	operations, err := jsonpatch.DecodePatch(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config patch %w", err)
+	}
+
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("config patch is empty")
+	}
+
+	res := make([]interface{}, 0, len(operations))
+
+	for i, operation := range operations {
+		path, err := operation.Path()
+		if err != nil {
+			return nil, fmt.Errorf("patch operation %d %w", i, err)
+		}
+
+		if err = validateTalosConfigPath(path); err != nil {
+			return nil, fmt.Errorf("patch operation %d %w", i, err)
+		}
+
+		op := map[string]interface{}{
+			"op":   operation.Kind(),
+			"path": path,
+		}
+
+		switch operation.Kind() {
+		case "add", "replace", "test":
+			raw, ok := operation["value"]
+			if !ok || raw == nil {
+				return nil, fmt.Errorf("patch operation %d %s is missing value", i, operation.Kind())
+			}
+
+			var value interface{}
+
+			if err = json.Unmarshal(*raw, &value); err != nil {
+				return nil, fmt.Errorf("patch operation %d %w", i, err)
+			}
+
+			op["value"] = value
+		case "move", "copy":
+			from, err := operation.From()
+			if err != nil {
+				return nil, fmt.Errorf("patch operation %d %w", i, err)
+			}
+
+			if err = validateTalosConfigPath(from); err != nil {
+				return nil, fmt.Errorf("patch operation %d %w", i, err)
+			}
+
+			op["from"] = from
+		case "remove":
+		default:
+			return nil, fmt.Errorf("patch operation %d has unsupported op %q", i, operation.Kind())
+		}
+
+		res = append(res, op)
+	}
+
+	return res, nil
+}
+
+// validateTalosConfigPatch checks the patch against the machine config of a current control plane machine.
+func (cluster *Cluster) validateTalosConfigPatch(ctx context.Context, configPatches []interface{}) error {
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, machine := range machines.Items {
+		if _, ok := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]; !ok || machine.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		config, err := cluster.BootstrapData(ctx, machine.GetName(), IncludeSecrets())
+		if err != nil {
+			return fmt.Errorf("failed to read machine %s config to validate the patch %w", machine.GetName(), err)
+		}
+
+		return applyTalosConfigPatch(config, configPatches)
+	}
+
+	return fmt.Errorf("no control plane machines to validate the config patch against")
+}
+
+// applyTalosConfigPatch applies the patch like the control plane provider does and decodes the patched config strictly.
+//
+// If the current config doesn't decode itself, it is generated by a newer Talos version than the vendored
+// config schema, so only the patch application is checked.
+func applyTalosConfigPatch(config []byte, configPatches []interface{}) error {
+	data, err := json.Marshal(configPatches)
+	if err != nil {
+		return err
+	}
+
+	patch, err := jsonpatch.DecodePatch(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse config patch %w", err)
+	}
+
+	patched, err := configpatcher.JSON6902(config, patch)
+	if err != nil {
+		return err
+	}
+
+	if _, err = configloader.NewFromBytes(config); err != nil {
+		fmt.Printf("current machine config schema is not supported, patched config keys are not validated: %s\n", err)
+
+		return nil
+	}
+
+	if _, err = configloader.NewFromBytes(patched); err != nil {
+		return fmt.Errorf("patched config is invalid %w", err)
+	}
+
+	return nil
+}
+
+// validateTalosConfigPath checks only the top level section of the path, nested Talos config keys are not validated.
+func validateTalosConfigPath(path string) error {
+	for _, section := range talosConfigSections {
+		if path == "/"+section || strings.HasPrefix(path, "/"+section+"/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("path %q is outside of Talos config sections: %s", path, strings.Join(talosConfigSections, ", "))
+}
+
+// controlPlaneMachineUIDs returns the UIDs of the current control plane machines.
+func (cluster *Cluster) controlPlaneMachineUIDs(ctx context.Context) (map[types.UID]struct{}, error) {
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[types.UID]struct{}{}
+
+	for _, machine := range machines.Items {
+		if _, ok := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]; ok {
+			res[machine.GetUID()] = struct{}{}
+		}
+	}
+
+	return res, nil
+}
+
+// waitControlPlaneRollout waits for all outdated control plane machines to be replaced.
+func (cluster *Cluster) waitControlPlaneRollout(ctx context.Context, outdated map[types.UID]struct{}) error {
+	return retry.Constant(60*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		controlPlane, err := cluster.ControlPlanes(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		replicas, _, err := unstructured.NestedInt64(controlPlane.Object, "spec", "replicas")
+		if err != nil {
+			return err
+		}
+
+		readyReplicas, _, err := unstructured.NestedInt64(controlPlane.Object, "status", "readyReplicas")
+		if err != nil {
+			return err
+		}
+
+		machines, err := cluster.machines(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		var total, updated int64
+
+		for _, machine := range machines.Items {
+			if _, ok := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]; !ok {
+				continue
+			}
+
+			total++
+
+			if _, ok := outdated[machine.GetUID()]; !ok {
+				updated++
+			}
+		}
+
+		if updated != replicas || total != replicas || readyReplicas != replicas {
+			return retry.ExpectedError(fmt.Errorf("control plane %d of %d machines updated, %d ready", updated, replicas, readyReplicas))
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyTalosConfigPatch(t *testing.T) {
+	// secretboxEncryptionSecret is not known to the vendored Talos v1.0 config schema
+	var config []string
+
+	for _, line := range strings.Split(talosMachineConfig, "\n") {
+		if !strings.Contains(line, "secretboxEncryptionSecret") {
+			config = append(config, line)
+		}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		patch string
+		err   bool
+	}{
+		{
+			name:  "install disk",
+			patch: `[{"op": "replace", "path": "/machine/install/disk", "value": "/dev/nvme0n1"}]`,
+		},
+		{
+			name: "yaml",
+			patch: `- op: add
+  path: /machine/kubelet/extraArgs/rotate-server-certificates
+  value: "true"
+- op: add
+  path: /cluster/allowSchedulingOnMasters
+  value: true
+`,
+		},
+		{
+			name:  "unknown key",
+			patch: `[{"op": "add", "path": "/machine/instal", "value": {"disk": "/dev/nvme0n1"}}]`,
+			err:   true,
+		},
+		{
+			name:  "unknown nested key",
+			patch: `[{"op": "add", "path": "/machine/install/disks", "value": "/dev/nvme0n1"}]`,
+			err:   true,
+		},
+		{
+			name:  "mistyped value",
+			patch: `[{"op": "replace", "path": "/machine/install/disk", "value": {"path": "/dev/nvme0n1"}}]`,
+			err:   true,
+		},
+		{
+			name:  "missing parent",
+			patch: `[{"op": "add", "path": "/machine/sysctls/net.ipv4.ip_forward", "value": "1"}]`,
+			err:   true,
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			configPatches, err := decodeTalosConfigPatch([]byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = applyTalosConfigPatch([]byte(strings.Join(config, "\n")), configPatches); (err != nil) != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestApplyTalosConfigPatchNewerSchema(t *testing.T) {
+	configPatches, err := decodeTalosConfigPatch([]byte(`[{"op": "add", "path": "/machine/install/disks", "value": "/dev/nvme0n1"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = applyTalosConfigPatch([]byte(talosMachineConfig), configPatches); err != nil {
+		t.Errorf("expected keys of the newer schema config not to be validated, got %v", err)
+	}
+
+	configPatches, err = decodeTalosConfigPatch([]byte(`[{"op": "add", "path": "/machine/sysctls/net.ipv4.ip_forward", "value": "1"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = applyTalosConfigPatch([]byte(talosMachineConfig), configPatches); err == nil {
+		t.Error("expected the patch which can't be applied to be rejected")
+	}
+}
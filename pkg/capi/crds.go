@@ -0,0 +1,168 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitForCRDsEstablished waits until all CRDs installed by the providers have the Established condition.
+//
+// CRDs are matched by the clusterctl provider label, so it covers core, bootstrap, control plane and infrastructure providers.
+func (clusterAPI *Manager) WaitForCRDsEstablished(ctx context.Context) error {
+	return retry.Constant(5*time.Minute, retry.WithUnits(5*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		var crds unstructured.UnstructuredList
+
+		crds.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "apiextensions.k8s.io",
+			Kind:    "CustomResourceDefinition",
+			Version: "v1",
+		})
+
+		if err := clusterAPI.runtimeClient.List(ctx, &crds, runtimeclient.HasLabels{clusterv1.ProviderLabelName}); err != nil {
+			return clusterAPI.retryable(err)
+		}
+
+		if len(crds.Items) == 0 {
+			return retry.ExpectedError(fmt.Errorf("no provider CRDs found"))
+		}
+
+		for _, crd := range crds.Items {
+			conditions, _, err := unstructured.NestedSlice(crd.Object, "status", "conditions")
+			if err != nil {
+				return err
+			}
+
+			established := false
+
+			for _, c := range conditions {
+				condition, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				if condition["type"] == "Established" && condition["status"] == "True" {
+					established = true
+
+					break
+				}
+			}
+
+			if !established {
+				return retry.ExpectedError(fmt.Errorf("CRD %s is not established", crd.GetName()))
+			}
+		}
+
+		return nil
+	})
+}
+
+// CRDVersions describes versions of a provider CRD.
+type CRDVersions struct {
+	// Storage is the version objects are persisted at.
+	Storage string
+
+	// Served are the versions served by the API server.
+	Served []string
+
+	// Stored are the versions objects might still be persisted at (CRD status.storedVersions).
+	Stored []string
+}
+
+// NeedsMigration returns true if some objects might be stored at a version other than the storage version.
+//
+// Objects should be migrated (e.g. by rewriting them) before an old stored version stops being served.
+func (versions CRDVersions) NeedsMigration() bool {
+	for _, stored := range versions.Stored {
+		if stored != versions.Storage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String implements fmt.Stringer.
+func (versions CRDVersions) String() string {
+	return fmt.Sprintf("%s (served %s, stored %s)", versions.Storage, strings.Join(versions.Served, ","), strings.Join(versions.Stored, ","))
+}
+
+// CRDStorageVersions reports storage, served and stored versions of the provider CRDs mapped by the CRD name.
+//
+// Values are formatted as "v1beta1 (served v1alpha4,v1beta1, stored v1alpha4,v1beta1)", use CRDVersions to get the versions as a struct.
+func (clusterAPI *Manager) CRDStorageVersions(ctx context.Context) (map[string]string, error) {
+	crdVersions, err := clusterAPI.CRDVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(crdVersions))
+
+	for name, versions := range crdVersions {
+		res[name] = versions.String()
+	}
+
+	return res, nil
+}
+
+// CRDVersions reports versions of the CRDs installed by the providers mapped by the CRD name.
+func (clusterAPI *Manager) CRDVersions(ctx context.Context) (map[string]CRDVersions, error) {
+	var crds unstructured.UnstructuredList
+
+	crds.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "apiextensions.k8s.io",
+		Kind:    "CustomResourceDefinition",
+		Version: "v1",
+	})
+
+	if err := clusterAPI.runtimeClient.List(ctx, &crds, runtimeclient.HasLabels{clusterv1.ProviderLabelName}); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]CRDVersions, len(crds.Items))
+
+	for _, crd := range crds.Items {
+		specVersions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+		if err != nil {
+			return nil, err
+		}
+
+		var versions CRDVersions
+
+		for _, v := range specVersions {
+			specVersion, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			name, _ := specVersion["name"].(string)
+
+			if served, _ := specVersion["served"].(bool); served {
+				versions.Served = append(versions.Served, name)
+			}
+
+			if storage, _ := specVersion["storage"].(bool); storage {
+				versions.Storage = name
+			}
+		}
+
+		if versions.Stored, _, err = unstructured.NestedStringSlice(crd.Object, "status", "storedVersions"); err != nil {
+			return nil, err
+		}
+
+		res[crd.GetName()] = versions
+	}
+
+	return res, nil
+}
@@ -0,0 +1,180 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/repository"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+)
+
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// applyCredentialsSecret sets clusterctl variables from the credentials secret keys.
+func (clusterAPI *Manager) applyCredentialsSecret(ctx context.Context) error {
+	data, err := clusterAPI.credentialsSecretData(ctx)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range data {
+		clusterAPI.cfg.Set(key, string(value))
+	}
+
+	return nil
+}
+
+// credentialsSecretVars returns the credentials secret keys as the provider variables, so that they are not put into the process env.
+func (clusterAPI *Manager) credentialsSecretVars(ctx context.Context) (infrastructure.Variables, error) {
+	data, err := clusterAPI.credentialsSecretData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make(infrastructure.Variables, len(data))
+
+	for key, value := range data {
+		vars[key] = string(value)
+	}
+
+	return vars, nil
+}
+
+// credentialsSecretData reads the credentials secret keys, it returns nil if the secret is not set.
+func (clusterAPI *Manager) credentialsSecretData(ctx context.Context) (map[string][]byte, error) {
+	ref := clusterAPI.options.CredentialsSecretRef
+	if ref == nil {
+		return nil, nil
+	}
+
+	secret, err := clusterAPI.clientset.CoreV1().Secrets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials secret %s %w", ref, err)
+	}
+
+	return secret.Data, nil
+}
+
+// UpdateProviderCredentials renders the infrastructure provider secrets with the new variables,
+// updates them in the management cluster and restarts the provider controllers.
+//
+// Variables are the same as the provider install variables, e.g. AWS_B64ENCODED_CREDENTIALS.
+// The secrets are rendered from the components of the installed provider version, as recorded in the clusterctl inventory.
+func (clusterAPI *Manager) UpdateProviderCredentials(ctx context.Context, providerName string, vars map[string]string) error {
+	var provider infrastructure.Provider
+
+	for _, p := range clusterAPI.providers {
+		if p.Name() == providerName {
+			provider = p
+
+			break
+		}
+	}
+
+	if provider == nil {
+		return fmt.Errorf("no provider with name %s is installed, installed providers: %s", providerName, strings.Join(clusterAPI.installedProviderNames(), ", "))
+	}
+
+	version, err := clusterAPI.installedProviderVersion(ctx, providerName, clusterctlv1.InfrastructureProviderType)
+	if err != nil {
+		return err
+	}
+
+	clusterAPI.patchConfig(vars)
+
+	repo, err := clusterAPI.providerRepository(providerName, clusterctlv1.InfrastructureProviderType)
+	if err != nil {
+		return err
+	}
+
+	components, err := repo.Components().Get(repository.ComponentsOptions{
+		Version:         version,
+		TargetNamespace: provider.Namespace(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render provider %s components %w", providerName, err)
+	}
+
+	updated := 0
+
+	for _, obj := range components.Objs() {
+		if obj.GetKind() != "Secret" {
+			continue
+		}
+
+		var (
+			secret   corev1.Secret
+			existing *corev1.Secret
+		)
+
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &secret); err != nil {
+			return err
+		}
+
+		if existing, err = clusterAPI.clientset.CoreV1().Secrets(secret.Namespace).Get(ctx, secret.Name, metav1.GetOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				if _, err = clusterAPI.clientset.CoreV1().Secrets(secret.Namespace).Create(ctx, &secret, metav1.CreateOptions{}); err != nil {
+					return err
+				}
+
+				updated++
+
+				continue
+			}
+
+			return err
+		}
+
+		existing.Data = secret.Data
+		existing.StringData = secret.StringData
+
+		if _, err = clusterAPI.clientset.CoreV1().Secrets(secret.Namespace).Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+
+		updated++
+	}
+
+	if updated == 0 {
+		return fmt.Errorf("provider %s components have no secrets to update", providerName)
+	}
+
+	deployments, err := clusterAPI.providerDeployments(ctx, clusterctlv1.ManifestLabel(providerName, clusterctlv1.InfrastructureProviderType))
+	if err != nil {
+		return err
+	}
+
+	restartedAt := time.Now().Format(time.RFC3339)
+
+	for i := range deployments {
+		deployment := &deployments[i]
+
+		if deployment.Spec.Template.Annotations == nil {
+			deployment.Spec.Template.Annotations = map[string]string{}
+		}
+
+		deployment.Spec.Template.Annotations[restartedAtAnnotation] = restartedAt
+
+		if deployment, err = clusterAPI.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+
+		if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+			return err
+		}
+	}
+
+	return clusterAPI.waitProviderReady(ctx, provider)
+}
@@ -9,16 +9,26 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/talos-systems/go-retry/retry"
 	"github.com/talos-systems/talos/pkg/machinery/constants"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
 )
@@ -30,6 +40,9 @@ type DeployOption func(opts *DeployOptions) error
 type DeployOptions struct {
 	providerOptions interface{}
 
+	// suppliedVariables are the template variables set from the deploy options and the provider cluster vars.
+	suppliedVariables map[string]struct{}
+
 	Provider          string
 	ProviderVersion   string
 	ClusterName       string
@@ -38,8 +51,15 @@ type DeployOptions struct {
 	KubernetesVersion string
 	TemplateFile      string
 	Template          []byte
+	Flavor            string
+	CheckCapacity     bool
+	OwnerReferences   []metav1.OwnerReference
 	ControlPlaneNodes int64
 	WorkerNodes       int64
+
+	// ControlPlaneEndpoint is the pre-allocated control plane endpoint (e.g. VIP or load balancer),
+	// it is passed to the template as CONTROL_PLANE_ENDPOINT and CONTROL_PLANE_PORT variables.
+	ControlPlaneEndpoint clusterv1.APIEndpoint
 }
 
 // DefaultDeployOptions default deployment settings.
@@ -71,7 +91,28 @@ func WithWorkerNodes(count int64) DeployOption {
 	}
 }
 
+// WithControlPlaneEndpoint sets the fixed control plane endpoint of the cluster.
+//
+// Cluster template should use CONTROL_PLANE_ENDPOINT variable, otherwise DeployCluster fails.
+func WithControlPlaneEndpoint(host string, port int32) DeployOption {
+	return func(o *DeployOptions) error {
+		if host == "" {
+			return fmt.Errorf("control plane endpoint host is empty")
+		}
+
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("control plane endpoint port %d is out of range", port)
+		}
+
+		o.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: host, Port: port}
+
+		return nil
+	}
+}
+
 // WithProvider sets cluster provider.
+//
+// Provider can be omitted only if a single infrastructure provider is installed.
 func WithProvider(name string) DeployOption {
 	return func(o *DeployOptions) error {
 		o.Provider = name
@@ -89,6 +130,17 @@ func WithProviderVersion(version string) DeployOption {
 	}
 }
 
+// WithFlavor picks the cluster template flavor from the provider repository.
+//
+// Flavor can't be used together with a custom template, available flavors are listed by TemplateFlavors.
+func WithFlavor(flavor string) DeployOption {
+	return func(o *DeployOptions) error {
+		o.Flavor = flavor
+
+		return nil
+	}
+}
+
 // WithTalosVersion sets Talos version.
 func WithTalosVersion(version string) DeployOption {
 	return func(o *DeployOptions) error {
@@ -152,18 +204,195 @@ func WithClusterNamespace(val string) DeployOption {
 	}
 }
 
+// WithCapacityCheck makes DeployCluster wait for the management cluster to have no resource pressure before creating the cluster.
+func WithCapacityCheck() DeployOption {
+	return func(o *DeployOptions) error {
+		o.CheckCapacity = true
+
+		return nil
+	}
+}
+
+// WithOwnerReferences sets owner references for all top level objects created for the cluster.
+//
+// Owners should be either cluster scoped or live in the same namespace as the created objects.
+func WithOwnerReferences(refs ...metav1.OwnerReference) DeployOption {
+	return func(o *DeployOptions) error {
+		o.OwnerReferences = append(o.OwnerReferences, refs...)
+
+		return nil
+	}
+}
+
 // DeployCluster creates a new cluster.
 //nolint:gocognit
 func (clusterAPI *Manager) DeployCluster(ctx context.Context, clusterName string, setters ...DeployOption) (*Cluster, error) {
+	options, template, err := clusterAPI.clusterTemplate(ctx, clusterName, false, setters...)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.CheckCapacity {
+		if err = clusterAPI.waitCapacity(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	objs := template.Objs()
+
+	if len(options.OwnerReferences) > 0 {
+		validated := map[string]struct{}{}
+
+		for i := range objs {
+			if _, ok := validated[objs[i].GetNamespace()]; !ok {
+				if err = clusterAPI.validateOwnerReferences(ctx, objs[i].GetNamespace(), options.OwnerReferences); err != nil {
+					return nil, err
+				}
+
+				validated[objs[i].GetNamespace()] = struct{}{}
+			}
+
+			objs[i].SetOwnerReferences(append(objs[i].GetOwnerReferences(), options.OwnerReferences...))
+		}
+	}
+
+	for _, obj := range objs {
+		if err = clusterAPI.runtimeClient.Create(ctx, &obj); err != nil {
+			return nil, err
+		}
+	}
+
+	deployedCluster, err := clusterAPI.NewCluster(ctx, options.ClusterName, options.ClusterNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+		return clusterAPI.retryable(clusterAPI.CheckClusterReady(ctx, deployedCluster))
+	}); err != nil {
+		return nil, err
+	}
+
+	return deployedCluster, nil
+}
+
+// validateOwnerReferences checks that owners exist and can be referenced from the objects in the namespace.
+func (clusterAPI *Manager) validateOwnerReferences(ctx context.Context, namespace string, refs []metav1.OwnerReference) error {
+	for _, ref := range refs {
+		gvk := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)
+
+		mapping, err := clusterAPI.runtimeClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve owner %s %s %w", ref.Kind, ref.Name, err)
+		}
+
+		key := types.NamespacedName{Name: ref.Name}
+
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if namespace == "" {
+				return fmt.Errorf("namespaced owner %s %s can not own cluster scoped objects", ref.Kind, ref.Name)
+			}
+
+			key.Namespace = namespace
+		}
+
+		var owner unstructured.Unstructured
+
+		owner.SetGroupVersionKind(gvk)
+
+		if err = clusterAPI.runtimeClient.Get(ctx, key, &owner); err != nil {
+			if errors.IsNotFound(err) && key.Namespace != "" {
+				return fmt.Errorf("owner %s %s not found in namespace %s, cross namespace owner references are not allowed", ref.Kind, ref.Name, key.Namespace)
+			}
+
+			return err
+		}
+
+		if owner.GetUID() != ref.UID {
+			return fmt.Errorf("owner %s %s UID mismatch: expected %s, got %s", ref.Kind, ref.Name, ref.UID, owner.GetUID())
+		}
+	}
+
+	return nil
+}
+
+// ValidateTemplate runs server-side dry-run apply for each object in the rendered manifests.
+//
+// Nothing is persisted, all admission and validation errors are aggregated into a single error.
+func (clusterAPI *Manager) ValidateTemplate(ctx context.Context, manifests []byte) error {
+	objs, err := utilyaml.ToUnstructured(manifests)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifests %w", err)
+	}
+
+	var errs []error
+
+	for i := range objs {
+		obj := &objs[i]
+
+		if err = clusterAPI.runtimeClient.Patch(ctx, obj, runtimeclient.Apply, runtimeclient.DryRunAll, runtimeclient.ForceOwnership, runtimeclient.FieldOwner(fieldOwner)); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// templateVariablesClusterName is the placeholder cluster name used to render the template when listing variables.
+const templateVariablesClusterName = "template-variables"
+
+// TemplateVariable is a variable declared by the cluster template.
+type TemplateVariable struct {
+	Name     string
+	Default  string
+	Required bool
+}
+
+// TemplateVariables returns variables declared by the cluster template sorted by name.
+//
+// Variables without defaults are required, they should be set as env variables or in the clusterctl config.
+// Variables set by Deploy from the deploy options (e.g. CLUSTER_NAME or KUBERNETES_VERSION)
+// and the provider options are not required. Listing the variables doesn't modify the clusterctl config.
+func (clusterAPI *Manager) TemplateVariables(ctx context.Context, setters ...DeployOption) ([]TemplateVariable, error) {
+	options, template, err := clusterAPI.clusterTemplate(ctx, templateVariablesClusterName, true, setters...)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := template.VariableMap()
+	res := make([]TemplateVariable, 0, len(variables))
+
+	for name, value := range variables {
+		_, supplied := options.suppliedVariables[name]
+
+		variable := TemplateVariable{
+			Name:     name,
+			Required: value == nil && !supplied,
+		}
+
+		if value != nil {
+			variable.Default = *value
+		}
+
+		res = append(res, variable)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// clusterTemplate resolves deploy options and the infrastructure provider and fetches the cluster template.
+func (clusterAPI *Manager) clusterTemplate(ctx context.Context, clusterName string, listVariablesOnly bool, setters ...DeployOption) (*DeployOptions, client.Template, error) {
 	if len(clusterAPI.providers) == 0 {
-		return nil, fmt.Errorf("no infrastructure providers are installed")
+		return nil, nil, fmt.Errorf("no infrastructure providers are installed")
 	}
 
 	options := DefaultDeployOptions()
 
 	for _, setter := range setters {
 		if err := setter(options); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -185,14 +414,50 @@ func (clusterAPI *Manager) DeployCluster(ctx context.Context, clusterName string
 		}
 
 		if provider == nil {
-			return nil, fmt.Errorf("no provider with name %s is installed", options.Provider)
+			return nil, nil, fmt.Errorf("no provider with name %s is installed, installed providers: %s", options.Provider, strings.Join(clusterAPI.installedProviderNames(), ", "))
 		}
 	} else {
+		names := clusterAPI.installedProviderNames()
+
+		if len(names) > 1 {
+			return nil, nil, fmt.Errorf("several infrastructure providers are installed, pick one with WithProvider: %s", strings.Join(names, ", "))
+		}
+
 		provider = clusterAPI.providers[0]
 	}
 
+	options.suppliedVariables = map[string]struct{}{}
+
+	var restores []func()
+
+	defer func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}()
+
+	// listing the variables shouldn't leave the placeholder values in the config
+	setVariables := func(vars infrastructure.Variables) {
+		temporary := make(map[string]string, len(vars))
+
+		for key, value := range vars {
+			if value != "" {
+				options.suppliedVariables[key] = struct{}{}
+				temporary[key] = value
+			}
+		}
+
+		if !listVariablesOnly {
+			clusterAPI.patchConfig(vars)
+
+			return
+		}
+
+		restores = append(restores, clusterAPI.cfg.setTemporary(temporary))
+	}
+
 	// set up env variables common for all providers
-	clusterAPI.patchConfig(infrastructure.Variables{
+	setVariables(infrastructure.Variables{
 		"TALOS_VERSION":               options.TalosVersion,
 		"KUBERNETES_VERSION":          options.KubernetesVersion,
 		"CLUSTER_NAME":                options.ClusterName,
@@ -205,10 +470,12 @@ func (clusterAPI *Manager) DeployCluster(ctx context.Context, clusterName string
 		ClusterName:              options.ClusterName,
 		ControlPlaneMachineCount: &options.ControlPlaneNodes,
 		WorkerMachineCount:       &options.WorkerNodes,
+		ListVariablesOnly:        listVariablesOnly,
+		YamlProcessor:            clusterAPI.options.TemplateProcessor,
 	}
 
 	if options.Template != nil {
-		file, err := ioutil.TempFile("", "clusterTemplate")
+		file, err := ioutil.TempFile(clusterAPI.tempDir(), templateTempFilePrefix)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -218,50 +485,170 @@ func (clusterAPI *Manager) DeployCluster(ctx context.Context, clusterName string
 		}
 
 		if _, err = file.Write(options.Template); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		defer file.Close() //nolint:errcheck
+		defer os.Remove(file.Name()) //nolint:errcheck
+		defer file.Close()           //nolint:errcheck
 	} else if options.TemplateFile != "" {
 		templateOptions.URLSource = &client.URLSourceOptions{
 			URL: options.TemplateFile,
 		}
 	}
 
+	if options.Flavor != "" {
+		if templateOptions.URLSource != nil {
+			return nil, nil, fmt.Errorf("template flavor can't be used with a custom template")
+		}
+
+		if err := clusterAPI.validateFlavor(ctx, provider.Name(), options.Flavor); err != nil {
+			return nil, nil, err
+		}
+
+		infrastructureProvider := provider.Name()
+		if v := clusterAPI.requestedVersion(provider.Name(), clusterctlv1.InfrastructureProviderType); v != "" {
+			infrastructureProvider += ":" + v
+		}
+
+		templateOptions.ProviderRepositorySource = &client.ProviderRepositorySourceOptions{
+			InfrastructureProvider: infrastructureProvider,
+			Flavor:                 options.Flavor,
+		}
+	}
+
 	vars, err := provider.ClusterVars(options.providerOptions)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	clusterAPI.patchConfig(vars)
+	setVariables(vars)
+
+	if options.ControlPlaneEndpoint.IsValid() {
+		endpoint := map[string]string{
+			"CONTROL_PLANE_ENDPOINT": options.ControlPlaneEndpoint.Host,
+			"CONTROL_PLANE_PORT":     strconv.Itoa(int(options.ControlPlaneEndpoint.Port)),
+		}
+
+		for key := range endpoint {
+			options.suppliedVariables[key] = struct{}{}
+		}
+
+		// endpoint is specific to the cluster, so it is set only for this template render
+		restores = append(restores, clusterAPI.cfg.setTemporary(endpoint))
+	}
 
 	template, err := provider.GetClusterTemplate(clusterAPI.client, templateOptions)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if !listVariablesOnly {
+		if err = validateTemplateObjects(template); err != nil {
+			return nil, nil, err
+		}
+
+		if options.ControlPlaneEndpoint.IsValid() {
+			if err = validateControlPlaneEndpoint(template, options.ControlPlaneEndpoint); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return options, template, nil
+}
+
+// validateControlPlaneEndpoint checks that the template accepts the fixed control plane endpoint.
+//
+// Objects setting spec.controlPlaneEndpoint (Cluster, infrastructure cluster) should use the requested endpoint.
+func validateControlPlaneEndpoint(template client.Template, endpoint clusterv1.APIEndpoint) error {
+	if _, ok := template.VariableMap()["CONTROL_PLANE_ENDPOINT"]; !ok {
+		return fmt.Errorf("cluster template doesn't accept a fixed control plane endpoint: CONTROL_PLANE_ENDPOINT variable is not used")
 	}
 
 	for _, obj := range template.Objs() {
-		if err = clusterAPI.runtimeClient.Create(ctx, &obj); err != nil {
-			return nil, err
+		host, found, err := unstructured.NestedString(obj.Object, "spec", "controlPlaneEndpoint", "host")
+		if err != nil {
+			return err
+		}
+
+		if !found || host == "" {
+			continue
+		}
+
+		port, _, err := unstructured.NestedInt64(obj.Object, "spec", "controlPlaneEndpoint", "port")
+		if err != nil {
+			return err
+		}
+
+		if host != endpoint.Host || (port != 0 && port != int64(endpoint.Port)) {
+			return fmt.Errorf("%s %s control plane endpoint %s:%d doesn't match requested %s", obj.GetKind(), obj.GetName(), host, port, endpoint)
 		}
 	}
 
-	deployedCluster, err := clusterAPI.NewCluster(ctx, options.ClusterName, options.ClusterNamespace)
+	return nil
+}
+
+// validateTemplateObjects checks that the processed template is a set of valid Kubernetes objects.
+func validateTemplateObjects(template client.Template) error {
+	objs := template.Objs()
+
+	if len(objs) == 0 {
+		return fmt.Errorf("cluster template has no objects")
+	}
+
+	for i, obj := range objs {
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return fmt.Errorf("cluster template object %d has no apiVersion or kind", i)
+		}
+
+		if obj.GetName() == "" {
+			return fmt.Errorf("cluster template %s object %d has no name", obj.GetKind(), i)
+		}
+	}
+
+	return nil
+}
+
+func (clusterAPI *Manager) validateFlavor(ctx context.Context, providerName, flavor string) error {
+	flavors, err := clusterAPI.TemplateFlavors(ctx, providerName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	if err = retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
-		return clusterAPI.CheckClusterReady(ctx, deployedCluster)
-	}); err != nil {
-		return nil, err
+	for _, f := range flavors {
+		if f == flavor {
+			return nil
+		}
 	}
 
-	return deployedCluster, nil
+	return fmt.Errorf("provider %s has no template flavor %q, available flavors: %s", providerName, flavor, strings.Join(flavors, ", "))
+}
+
+// installedProviderNames returns sorted unique names of the installed infrastructure providers.
+func (clusterAPI *Manager) installedProviderNames() []string {
+	seen := map[string]struct{}{}
+	names := []string{}
+
+	for _, p := range clusterAPI.providers {
+		if _, ok := seen[p.Name()]; ok {
+			continue
+		}
+
+		seen[p.Name()] = struct{}{}
+		names = append(names, p.Name())
+	}
+
+	sort.Strings(names)
+
+	return names
 }
 
 // DestroyCluster deletes cluster.
-func (clusterAPI *Manager) DestroyCluster(ctx context.Context, name, namespace string) error {
+//
+// With DryRun, deletion is validated by the server, but the cluster is not deleted.
+func (clusterAPI *Manager) DestroyCluster(ctx context.Context, name, namespace string, setters ...OperationOption) error {
+	opts := newOperationOptions(setters)
+
 	cluster := &unstructured.Unstructured{}
 	cluster.SetName(name)
 	cluster.SetNamespace(namespace)
@@ -271,24 +658,111 @@ func (clusterAPI *Manager) DestroyCluster(ctx context.Context, name, namespace s
 		Version: clusterAPI.version,
 	})
 
-	if err := clusterAPI.runtimeClient.Delete(ctx, cluster); err != nil {
+	if err := clusterAPI.runtimeClient.Delete(ctx, cluster, opts.deleteOptions()...); err != nil {
 		if errors.IsNotFound(err) {
+			if clusterAPI.options.DeleteClusterSecrets && !opts.DryRun {
+				return clusterAPI.deleteClusterSecrets(ctx, name, namespace)
+			}
+
 			return nil
 		}
 
 		return err
 	}
 
-	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+	if opts.DryRun {
+		fmt.Printf("dry run: cluster %s/%s would be deleted\n", namespace, name)
+
+		return nil
+	}
+
+	if err := retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
 		err := clusterAPI.runtimeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, cluster)
 		if err != nil {
 			if errors.IsNotFound(err) {
 				return nil
 			}
 
-			return err
+			return clusterAPI.retryable(err)
 		}
 
 		return retry.ExpectedError(fmt.Errorf("cluster is being deleted"))
+	}); err != nil {
+		return err
+	}
+
+	if clusterAPI.options.DeleteClusterSecrets {
+		return clusterAPI.deleteClusterSecrets(ctx, name, namespace)
+	}
+
+	return nil
+}
+
+// deleteClusterSecrets removes secrets of the deleted cluster which were not garbage collected.
+//
+// Only secrets labeled with the cluster name or owned by the Cluster are deleted.
+func (clusterAPI *Manager) deleteClusterSecrets(ctx context.Context, name, namespace string) error {
+	secrets, err := clusterAPI.clusterSecrets(ctx, name, namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		if err = clusterAPI.clientset.CoreV1().Secrets(namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+
+			return fmt.Errorf("failed to delete cluster secret %s/%s %w", namespace, secret.Name, err)
+		}
+
+		fmt.Printf("deleted cluster secret %s/%s\n", namespace, secret.Name)
+	}
+
+	return nil
+}
+
+// IsClusterDeleted checks that the Cluster object and all the objects labeled with the cluster name
+// (machines, infrastructure, bootstrap and control plane objects) are gone.
+func (clusterAPI *Manager) IsClusterDeleted(ctx context.Context, name, namespace string) (bool, error) {
+	cluster := &unstructured.Unstructured{}
+	cluster.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
 	})
+
+	err := clusterAPI.runtimeClient.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, cluster)
+	if err == nil {
+		return false, nil
+	}
+
+	if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	kinds, err := clusterAPI.capiKinds()
+	if err != nil {
+		return false, err
+	}
+
+	for _, gvk := range kinds {
+		var list unstructured.UnstructuredList
+
+		list.SetGroupVersionKind(gvk)
+
+		if err = clusterAPI.runtimeClient.List(ctx, &list,
+			runtimeclient.InNamespace(namespace),
+			runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: name},
+			runtimeclient.Limit(1),
+		); err != nil {
+			return false, err
+		}
+
+		if len(list.Items) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
 }
@@ -0,0 +1,99 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/tree"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+)
+
+// infrastructureGroupSuffix matches API groups of the infrastructure provider objects.
+const infrastructureGroupSuffix = "infrastructure.cluster.x-k8s.io"
+
+// ClusterDescription is the clusterctl object tree of the cluster enriched with the infrastructure provider details.
+type ClusterDescription struct {
+	*tree.ObjectTree
+
+	// Details maps tree object UIDs to the details reported by the infrastructure providers, e.g. instance IDs.
+	Details map[types.UID]map[string]string
+}
+
+// DescribeCluster builds the same object tree as clusterctl describe cluster.
+//
+// Infrastructure objects of the tree are passed to the installed providers implementing infrastructure.Describer.
+func (clusterAPI *Manager) DescribeCluster(ctx context.Context, name, namespace string) (*ClusterDescription, error) {
+	objectTree, err := clusterAPI.client.DescribeCluster(client.DescribeClusterOptions{
+		Kubeconfig:  clusterAPI.kubeconfig,
+		Namespace:   namespace,
+		ClusterName: name,
+		Grouping:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ClusterDescription{
+		ObjectTree: objectTree,
+		Details:    map[types.UID]map[string]string{},
+	}
+
+	describers := []infrastructure.Describer{}
+
+	for _, provider := range clusterAPI.providers {
+		if describer, ok := provider.(infrastructure.Describer); ok {
+			describers = append(describers, describer)
+		}
+	}
+
+	if len(describers) == 0 {
+		return res, nil
+	}
+
+	if err = res.describe(ctx, describers, objectTree.GetRoot()); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// describe collects details of the object and its descendants from the describers.
+func (description *ClusterDescription) describe(ctx context.Context, describers []infrastructure.Describer, obj runtimeclient.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok && strings.HasSuffix(u.GroupVersionKind().Group, infrastructureGroupSuffix) {
+		for _, describer := range describers {
+			details, err := describer.Describe(ctx, *u)
+			if err != nil {
+				return err
+			}
+
+			if len(details) == 0 {
+				continue
+			}
+
+			if description.Details[u.GetUID()] == nil {
+				description.Details[u.GetUID()] = map[string]string{}
+			}
+
+			for k, v := range details {
+				description.Details[u.GetUID()][k] = v
+			}
+		}
+	}
+
+	for _, child := range description.GetObjectsByParent(obj.GetUID()) {
+		if err := description.describe(ctx, describers, child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
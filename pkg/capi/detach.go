@@ -0,0 +1,220 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DetachOptions defines additional optional parameters for detach method.
+type DetachOptions struct {
+	StripOwnerReferences bool
+}
+
+// DetachOption optional detach parameter setter.
+type DetachOption func(*DetachOptions)
+
+// StripOwnerReferences removes owner references from the detached objects,
+// so that deleting the Cluster doesn't garbage collect them.
+func StripOwnerReferences() DetachOption {
+	return func(opts *DetachOptions) {
+		opts.StripOwnerReferences = true
+	}
+}
+
+// Detach stops managing the cluster without destroying it.
+//
+// The cluster is paused and CAPI finalizers (core and providers ones, in the cluster.x-k8s.io domain) are removed
+// from all cluster objects, so deleting them afterwards leaves the underlying infrastructure intact.
+// Other finalizers are kept.
+// This is dangerous and irreversible, so Options.AllowDetach should be set explicitly.
+func (cluster *Cluster) Detach(ctx context.Context, setters ...DetachOption) error {
+	if !cluster.manager.options.AllowDetach {
+		return fmt.Errorf("detaching clusters is disabled, set AllowDetach option to enable it")
+	}
+
+	var opts DetachOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	if err := cluster.setPaused(ctx, true); err != nil {
+		return err
+	}
+
+	objects, err := cluster.objects(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range objects {
+		obj := &objects[i]
+
+		obj.SetFinalizers(nonCAPIFinalizers(obj.GetFinalizers()))
+
+		if opts.StripOwnerReferences {
+			obj.SetOwnerReferences(nil)
+		}
+
+		if err = cluster.manager.runtimeClient.Update(ctx, obj); err != nil {
+			return fmt.Errorf("failed to detach %s %s %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// nonCAPIFinalizers filters out the finalizers in the cluster.x-k8s.io domain,
+// e.g. cluster.cluster.x-k8s.io or metalmachine.infrastructure.cluster.x-k8s.io.
+func nonCAPIFinalizers(finalizers []string) []string {
+	var res []string
+
+	for _, finalizer := range finalizers {
+		domain := finalizer
+
+		if i := strings.Index(finalizer, "/"); i >= 0 {
+			domain = finalizer[:i]
+		}
+
+		if domain == "cluster.x-k8s.io" || strings.HasSuffix(domain, ".cluster.x-k8s.io") {
+			continue
+		}
+
+		res = append(res, finalizer)
+	}
+
+	return res
+}
+
+// Pause stops CAPI controllers from reconciling the cluster objects.
+func (cluster *Cluster) Pause(ctx context.Context, setters ...OperationOption) error {
+	return cluster.setPaused(ctx, true, newOperationOptions(setters).updateOptions()...)
+}
+
+// Resume resumes reconciling the paused cluster.
+func (cluster *Cluster) Resume(ctx context.Context, setters ...OperationOption) error {
+	return cluster.setPaused(ctx, false, newOperationOptions(setters).updateOptions()...)
+}
+
+// setPaused sets cluster spec.paused field.
+func (cluster *Cluster) setPaused(ctx context.Context, paused bool, opts ...runtimeclient.UpdateOption) error {
+	if err := cluster.sync(ctx); err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(cluster.cluster.Object, paused, "spec", "paused"); err != nil {
+		return err
+	}
+
+	return cluster.manager.runtimeClient.Update(ctx, &cluster.cluster, opts...)
+}
+
+// objects fetches the Cluster, its control plane and infrastructure objects
+// and all CAPI objects labeled with the cluster name.
+func (cluster *Cluster) objects(ctx context.Context) ([]unstructured.Unstructured, error) {
+	if err := cluster.sync(ctx); err != nil {
+		return nil, err
+	}
+
+	res := []unstructured.Unstructured{cluster.cluster}
+	seen := map[types.UID]struct{}{cluster.cluster.GetUID(): {}}
+
+	for _, key := range [][]string{{"spec", "controlPlaneRef"}, {"spec", "infrastructureRef"}} {
+		r, err := getRef(cluster.cluster.Object, key...)
+		if err != nil {
+			return nil, err
+		}
+
+		var obj unstructured.Unstructured
+
+		obj.SetGroupVersionKind(r.gvk)
+
+		if err = cluster.manager.runtimeClient.Get(ctx, r.NamespacedName, &obj); err != nil {
+			return nil, err
+		}
+
+		res = append(res, obj)
+		seen[obj.GetUID()] = struct{}{}
+	}
+
+	kinds, err := cluster.manager.capiKinds()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, gvk := range kinds {
+		var list unstructured.UnstructuredList
+
+		list.SetGroupVersionKind(gvk)
+
+		if err = cluster.manager.runtimeClient.List(ctx, &list,
+			runtimeclient.InNamespace(cluster.namespace),
+			runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: cluster.name},
+		); err != nil {
+			return nil, err
+		}
+
+		for _, obj := range list.Items {
+			if _, ok := seen[obj.GetUID()]; ok {
+				continue
+			}
+
+			res = append(res, obj)
+			seen[obj.GetUID()] = struct{}{}
+		}
+	}
+
+	return res, nil
+}
+
+// capiKinds returns namespaced listable kinds from all cluster API groups (core and providers).
+func (clusterAPI *Manager) capiKinds() ([]schema.GroupVersionKind, error) {
+	resources, err := clusterAPI.clientset.Discovery().ServerPreferredNamespacedResources()
+	if err != nil {
+		return nil, err
+	}
+
+	var kinds []schema.GroupVersionKind
+
+	for _, list := range resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasSuffix(gv.Group, "cluster.x-k8s.io") {
+			continue
+		}
+
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+
+			listable := false
+
+			for _, verb := range resource.Verbs {
+				if verb == "list" {
+					listable = true
+				}
+			}
+
+			if listable {
+				kinds = append(kinds, gv.WithKind(resource.Kind))
+			}
+		}
+	}
+
+	return kinds, nil
+}
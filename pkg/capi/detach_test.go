@@ -0,0 +1,44 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNonCAPIFinalizers(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		finalizers []string
+		expected   []string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:       "capi",
+			finalizers: []string{"cluster.cluster.x-k8s.io", "machine.cluster.x-k8s.io", "metalmachine.infrastructure.cluster.x-k8s.io"},
+		},
+		{
+			name:       "mixed",
+			finalizers: []string{"kubernetes", "talos.controlplane.cluster.x-k8s.io", "example.com/protect", "cluster.x-k8s.io/cleanup"},
+			expected:   []string{"kubernetes", "example.com/protect"},
+		},
+		{
+			name:       "lookalike",
+			finalizers: []string{"notcluster.x-k8s.io", "example.com/cluster.x-k8s.io"},
+			expected:   []string{"notcluster.x-k8s.io", "example.com/cluster.x-k8s.io"},
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if res := nonCAPIFinalizers(tt.finalizers); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OperationOptions defines additional optional parameters for the mutating cluster operations.
+type OperationOptions struct {
+	DryRun bool
+}
+
+// OperationOption optional mutating operation parameter setter.
+type OperationOption func(*OperationOptions)
+
+// DryRun sends all changes with server-side dry-run, so they are validated and admitted, but not persisted.
+//
+// Operation reports what would change and doesn't wait for the result.
+func DryRun() OperationOption {
+	return func(opts *OperationOptions) {
+		opts.DryRun = true
+	}
+}
+
+func newOperationOptions(setters []OperationOption) OperationOptions {
+	var opts OperationOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	return opts
+}
+
+func (opts OperationOptions) updateOptions() []runtimeclient.UpdateOption {
+	if opts.DryRun {
+		return []runtimeclient.UpdateOption{runtimeclient.DryRunAll}
+	}
+
+	return nil
+}
+
+func (opts OperationOptions) deleteOptions() []runtimeclient.DeleteOption {
+	if opts.DryRun {
+		return []runtimeclient.DeleteOption{runtimeclient.DryRunAll}
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+)
+
+const (
+	defaultKindClusterName = "capi-bootstrap"
+	defaultKindBinary      = "kind"
+)
+
+// EphemeralBootstrapOptions defines parameters of BootstrapWithEphemeralCluster.
+type EphemeralBootstrapOptions struct {
+	// Options are the Manager options of both the bootstrap and the management cluster, Kubeconfig and Proxy are overridden.
+	Options Options
+
+	// BootstrapKubeconfig points to an existing bootstrap cluster, a kind cluster is created if it is not set.
+	// Existing bootstrap cluster is not deleted.
+	BootstrapKubeconfig client.Kubeconfig
+
+	// KindClusterName defaults to capi-bootstrap.
+	KindClusterName string
+
+	// KindBinary is the kind executable, defaults to kind in the PATH.
+	KindBinary string
+
+	// KindNodeImage overrides the kind node image, e.g. to pick the Kubernetes version.
+	KindNodeImage string
+
+	// ClusterName is the name of the management cluster deployed from the bootstrap cluster.
+	ClusterName string
+
+	// DeployOptions are passed to DeployCluster creating the management cluster.
+	DeployOptions []DeployOption
+
+	// KubeconfigPath is the path the management cluster kubeconfig is written to.
+	KubeconfigPath string
+
+	// BootstrapKubeconfigPath is the path the kind cluster kubeconfig is written to, defaults to KubeconfigPath with .bootstrap suffix.
+	// It is removed together with the kind cluster and kept if the kind cluster is kept.
+	BootstrapKubeconfigPath string
+}
+
+// BootstrapWithEphemeralCluster creates a self-hosted management cluster using a temporary bootstrap cluster.
+//
+// Cluster API is installed into the bootstrap cluster (a local kind cluster unless BootstrapKubeconfig is set),
+// the management cluster is deployed from there, cluster API is installed into the management cluster
+// and the cluster objects are moved to it. The bootstrap cluster is deleted afterwards.
+//
+// If anything fails before the move, the management cluster is destroyed and the kind cluster is deleted.
+// If the move fails, the kind cluster and its kubeconfig are kept, as it still might own the management cluster objects.
+//
+// Returned Manager should be closed by the caller.
+//
+//nolint:gocognit,gocyclo,cyclop
+func BootstrapWithEphemeralCluster(ctx context.Context, opts EphemeralBootstrapOptions) (_ *Manager, err error) {
+	if opts.ClusterName == "" {
+		return nil, fmt.Errorf("management cluster name is required")
+	}
+
+	if opts.KubeconfigPath == "" {
+		return nil, fmt.Errorf("management cluster kubeconfig path is required")
+	}
+
+	if opts.KindClusterName == "" {
+		opts.KindClusterName = defaultKindClusterName
+	}
+
+	if opts.KindBinary == "" {
+		opts.KindBinary = defaultKindBinary
+	}
+
+	if opts.BootstrapKubeconfigPath == "" {
+		opts.BootstrapKubeconfigPath = opts.KubeconfigPath + ".bootstrap"
+	}
+
+	bootstrapKubeconfig := opts.BootstrapKubeconfig
+	createKind := bootstrapKubeconfig.Path == ""
+
+	if createKind {
+		bootstrapKubeconfig = client.Kubeconfig{
+			Path:    opts.BootstrapKubeconfigPath,
+			Context: "kind-" + opts.KindClusterName,
+		}
+	}
+
+	var (
+		bootstrap     *Manager
+		management    *Manager
+		deployed      *Cluster
+		keepBootstrap bool
+	)
+
+	// cleanup is registered before the kind cluster is created, as a failed create might leave it behind
+	defer func() {
+		if err != nil && management != nil {
+			management.Close() //nolint:errcheck
+		}
+
+		if err != nil && deployed != nil && !keepBootstrap {
+			fmt.Printf("destroying management cluster %s/%s after the failed bootstrap\n", deployed.namespace, deployed.name)
+
+			if e := bootstrap.DestroyCluster(ctx, deployed.name, deployed.namespace); e != nil {
+				fmt.Printf("warning: failed to destroy management cluster %s/%s, bootstrap cluster kubeconfig %s is kept: %s\n",
+					deployed.namespace, deployed.name, bootstrapKubeconfig.Path, e)
+
+				keepBootstrap = true
+			}
+		}
+
+		if bootstrap != nil {
+			bootstrap.Close() //nolint:errcheck
+		}
+
+		if !createKind || keepBootstrap {
+			return
+		}
+
+		if e := deleteKindCluster(opts); e != nil {
+			fmt.Printf("warning: failed to delete bootstrap cluster %s: %s\n", opts.KindClusterName, e)
+
+			return
+		}
+
+		if e := os.Remove(bootstrapKubeconfig.Path); e != nil && !os.IsNotExist(e) {
+			fmt.Printf("warning: failed to remove bootstrap cluster kubeconfig %s: %s\n", bootstrapKubeconfig.Path, e)
+		}
+	}()
+
+	if createKind {
+		if err = createKindCluster(ctx, opts, bootstrapKubeconfig.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	bootstrapOptions := opts.Options
+	bootstrapOptions.Proxy = nil
+	bootstrapOptions.Kubeconfig = bootstrapKubeconfig
+
+	if bootstrap, err = NewManager(ctx, bootstrapOptions); err != nil {
+		return nil, fmt.Errorf("failed to connect to the bootstrap cluster %w", err)
+	}
+
+	if err = bootstrap.Install(ctx); err != nil {
+		return nil, fmt.Errorf("failed to install cluster API into the bootstrap cluster %w", err)
+	}
+
+	if deployed, err = bootstrap.DeployCluster(ctx, opts.ClusterName, opts.DeployOptions...); err != nil {
+		return nil, fmt.Errorf("failed to deploy management cluster %w", err)
+	}
+
+	kubeconfig, err := deployed.GetWorkloadKubeconfig(ctx, WithPing())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ioutil.WriteFile(opts.KubeconfigPath, kubeconfig, 0o600); err != nil {
+		return nil, err
+	}
+
+	managementOptions := opts.Options
+	managementOptions.Proxy = nil
+	managementOptions.Kubeconfig = client.Kubeconfig{Path: opts.KubeconfigPath}
+
+	if management, err = NewManager(ctx, managementOptions); err != nil {
+		return nil, fmt.Errorf("failed to connect to the management cluster %w", err)
+	}
+
+	if err = management.Install(ctx); err != nil {
+		return nil, fmt.Errorf("failed to install cluster API into the management cluster %w", err)
+	}
+
+	// from now on the bootstrap cluster is kept on failures, objects might be already moved partially
+	keepBootstrap = true
+
+	if _, err = bootstrap.Move(ctx, managementOptions.Kubeconfig, deployed.namespace); err != nil {
+		return nil, fmt.Errorf("failed to move cluster %s/%s to the management cluster, bootstrap cluster kubeconfig %s is kept %w",
+			deployed.namespace, deployed.name, bootstrapKubeconfig.Path, err)
+	}
+
+	keepBootstrap = false
+
+	return management, nil
+}
+
+// createKindCluster creates the kind cluster writing its kubeconfig to the path.
+func createKindCluster(ctx context.Context, opts EphemeralBootstrapOptions, kubeconfigPath string) error {
+	args := []string{"create", "cluster", "--name", opts.KindClusterName, "--kubeconfig", kubeconfigPath, "--wait", "5m"}
+
+	if opts.KindNodeImage != "" {
+		args = append(args, "--image", opts.KindNodeImage)
+	}
+
+	fmt.Printf("creating bootstrap kind cluster %s\n", opts.KindClusterName)
+
+	return runKind(ctx, opts.KindBinary, args...)
+}
+
+// deleteKindCluster deletes the kind cluster, it is not bound to the context to clean up after cancellation.
+func deleteKindCluster(opts EphemeralBootstrapOptions) error {
+	fmt.Printf("deleting bootstrap kind cluster %s\n", opts.KindClusterName)
+
+	return runKind(context.Background(), opts.KindBinary, "delete", "cluster", "--name", opts.KindClusterName)
+}
+
+func runKind(ctx context.Context, binary string, args ...string) error {
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kind %s failed: %s %w", args[0], bytes.TrimSpace(output.Bytes()), err)
+	}
+
+	return nil
+}
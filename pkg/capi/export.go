@@ -0,0 +1,225 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// ExportOptions defines additional optional parameters for export method.
+type ExportOptions struct {
+	Secrets bool
+	Redact  bool
+}
+
+// ExportOption optional export parameter setter.
+type ExportOption func(*ExportOptions)
+
+// ExportSecrets includes the cluster secrets (kubeconfig, CA, talosconfig) in the export.
+func ExportSecrets() ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Secrets = true
+	}
+}
+
+// ExportRedacted redacts the keys, tokens and other secrets in the exported objects, like BootstrapData does,
+// including the Talos config patches and kubeadm files content.
+//
+// Redacted export is meant for troubleshooting and can't be imported, so the Cluster keeps its actual spec.paused value.
+func ExportRedacted() ExportOption {
+	return func(opts *ExportOptions) {
+		opts.Redact = true
+	}
+}
+
+// Export serializes the cluster object graph into multi-document YAML which can be applied to another management cluster.
+//
+// Server-populated fields, status and owner references are stripped, CAPI controllers restore owner references
+// once the objects are imported. Owner references of the secrets are kept, as the controllers don't restore them,
+// so the export with the secrets should be imported with Import which remaps them to the imported owners:
+// applied as is, the secrets reference the owners UIDs of the source management cluster and are garbage collected.
+//
+// The Cluster is exported with spec.paused set, so the controllers of the target management cluster don't reconcile
+// the imported objects while the source management cluster still manages them. The source cluster is not modified:
+// once the objects are imported, Detach the source cluster and Resume the imported one.
+func (cluster *Cluster) Export(ctx context.Context, setters ...ExportOption) ([]byte, error) {
+	var opts ExportOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	objects, err := cluster.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Secrets {
+		var secrets corev1.SecretList
+
+		if err = cluster.manager.runtimeClient.List(ctx, &secrets,
+			runtimeclient.InNamespace(cluster.namespace),
+			runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: cluster.name},
+		); err != nil {
+			return nil, err
+		}
+
+		for i := range secrets.Items {
+			var obj map[string]interface{}
+
+			if obj, err = runtime.DefaultUnstructuredConverter.ToUnstructured(&secrets.Items[i]); err != nil {
+				return nil, err
+			}
+
+			secret := unstructured.Unstructured{Object: obj}
+			secret.SetAPIVersion("v1")
+			secret.SetKind("Secret")
+
+			objects = append(objects, secret)
+		}
+	}
+
+	var buf bytes.Buffer
+
+	for i := range objects {
+		obj := objects[i].DeepCopy()
+
+		obj.SetUID("")
+		obj.SetResourceVersion("")
+		obj.SetGeneration(0)
+		obj.SetCreationTimestamp(metav1.Time{})
+		obj.SetManagedFields(nil)
+
+		if obj.GetKind() != "Secret" {
+			obj.SetOwnerReferences(nil)
+		}
+
+		obj.SetSelfLink("")
+		unstructured.RemoveNestedField(obj.Object, "status")
+
+		if !opts.Redact && obj.GroupVersionKind().Group == clusterv1.GroupVersion.Group && obj.GetKind() == "Cluster" {
+			if err = unstructured.SetNestedField(obj.Object, true, "spec", "paused"); err != nil {
+				return nil, err
+			}
+		}
+
+		if opts.Redact {
+			redactObject(obj)
+		}
+
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// redactObject redacts the secrets in the exported object.
+//
+// Secret data and the last applied configuration annotation, which duplicates the object spec, are redacted as a whole.
+func redactObject(obj *unstructured.Unstructured) {
+	if obj.GetKind() == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			if value, ok := obj.Object[field]; ok {
+				obj.Object[field] = redactAll(value)
+			}
+		}
+	}
+
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+			annotations[corev1.LastAppliedConfigAnnotation] = redacted
+
+			obj.SetAnnotations(annotations)
+		}
+	}
+
+	redact(obj.Object)
+}
+
+// Import creates the objects exported by Cluster.Export in the management cluster.
+//
+// Secrets are created after the other objects and their owner references are remapped to the imported owners,
+// like clusterctl move does. References to the owners which don't exist in the management cluster are dropped.
+//
+// The imported Cluster stays paused, as exported. Resume it once the source cluster is detached,
+// otherwise both management clusters reconcile the same infrastructure.
+func (clusterAPI *Manager) Import(ctx context.Context, manifests []byte) error {
+	objs, err := utilyaml.ToUnstructured(manifests)
+	if err != nil {
+		return fmt.Errorf("failed to parse manifests %w", err)
+	}
+
+	sort.SliceStable(objs, func(i, j int) bool {
+		return objs[i].GetKind() != "Secret" && objs[j].GetKind() == "Secret"
+	})
+
+	for i := range objs {
+		obj := &objs[i]
+
+		if err = clusterAPI.remapOwnerReferences(ctx, obj); err != nil {
+			return err
+		}
+
+		if err = clusterAPI.runtimeClient.Create(ctx, obj); err != nil {
+			return fmt.Errorf("failed to import %s %s/%s %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// remapOwnerReferences points the object owner references to the UIDs of the owners in the management cluster.
+func (clusterAPI *Manager) remapOwnerReferences(ctx context.Context, obj *unstructured.Unstructured) error {
+	refs := obj.GetOwnerReferences()
+	if len(refs) == 0 {
+		return nil
+	}
+
+	remapped := make([]metav1.OwnerReference, 0, len(refs))
+
+	for _, ref := range refs {
+		owner := &unstructured.Unstructured{}
+		owner.SetAPIVersion(ref.APIVersion)
+		owner.SetKind(ref.Kind)
+
+		if err := clusterAPI.runtimeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: ref.Name}, owner); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return fmt.Errorf("failed to get %s %s owner %s %s %w", obj.GetKind(), obj.GetName(), ref.Kind, ref.Name, err)
+		}
+
+		ref.UID = owner.GetUID()
+		remapped = append(remapped, ref)
+	}
+
+	obj.SetOwnerReferences(remapped)
+
+	return nil
+}
@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"strings"
+	"testing"
+
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+const exportedObjects = `apiVersion: controlplane.cluster.x-k8s.io/v1alpha3
+kind: TalosControlPlane
+metadata:
+  name: test-cp
+  namespace: default
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"spec":{"controlPlaneConfig":{"controlplane":{"configPatches":[{"op":"add","path":"/machine/token","value":"applied-token-62fd"}]}}}}'
+spec:
+  replicas: 3
+  version: v1.23.5
+  controlPlaneConfig:
+    controlplane:
+      generateType: controlplane
+      configPatches:
+        - op: add
+          path: /cluster/aescbcEncryptionSecret
+          value: patched-aescbc-0b7e
+        - op: add
+          path: /machine/install/disk
+          value: /dev/nvme0n1
+---
+apiVersion: bootstrap.cluster.x-k8s.io/v1beta1
+kind: KubeadmConfig
+metadata:
+  name: test-worker
+  namespace: default
+spec:
+  files:
+    - path: /etc/kubernetes/azure.json
+      owner: root:root
+      permissions: "0644"
+      content: '{"aadClientSecret": "azure-client-secret-a7c3"}'
+  joinConfiguration:
+    discovery:
+      bootstrapToken:
+        apiServerEndpoint: 10.0.0.1:6443
+        token: bn3x8k.9gkl2lwb0x7dd4ms
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: test-kubeconfig
+  namespace: default
+data:
+  value: a3ViZWNvbmZpZy1kYXRhLTQ0ZTE=
+`
+
+func TestRedactObject(t *testing.T) {
+	objects, err := utilyaml.ToUnstructured([]byte(exportedObjects))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res strings.Builder
+
+	for i := range objects {
+		redactObject(&objects[i])
+
+		data, err := yaml.Marshal(objects[i].Object)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res.Write(data)
+	}
+
+	for _, secret := range []string{
+		"applied-token-62fd",
+		"patched-aescbc-0b7e",
+		"azure-client-secret-a7c3",
+		"bn3x8k.9gkl2lwb0x7dd4ms",
+		"a3ViZWNvbmZpZy1kYXRhLTQ0ZTE=",
+	} {
+		if strings.Contains(res.String(), secret) {
+			t.Errorf("secret %q is not redacted:\n%s", secret, res.String())
+		}
+	}
+
+	for _, value := range []string{"/dev/nvme0n1", "/etc/kubernetes/azure.json", "joinConfiguration", "test-kubeconfig"} {
+		if !strings.Contains(res.String(), value) {
+			t.Errorf("value %q is redacted:\n%s", value, res.String())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const featureGatesArg = "--feature-gates="
+
+// featureGateVariables maps core provider feature gates to the clusterctl variables
+// used in the components --feature-gates argument.
+var featureGateVariables = map[string]string{
+	"MachinePool":        "EXP_MACHINE_POOL",
+	"ClusterResourceSet": "EXP_CLUSTER_RESOURCE_SET",
+	"ClusterTopology":    "CLUSTER_TOPOLOGY",
+}
+
+// configureFeatureGates sets clusterctl variables for the requested core provider feature gates.
+func (clusterAPI *Manager) configureFeatureGates() error {
+	if len(clusterAPI.options.FeatureGates) == 0 {
+		return nil
+	}
+
+	gates := make([]string, 0, len(clusterAPI.options.FeatureGates))
+
+	for gate, enabled := range clusterAPI.options.FeatureGates {
+		variable, ok := featureGateVariables[gate]
+		if !ok {
+			return fmt.Errorf("unknown feature gate %q, supported feature gates: %s", gate, strings.Join(supportedFeatureGates(), ", "))
+		}
+
+		clusterAPI.cfg.Set(variable, strconv.FormatBool(enabled))
+
+		gates = append(gates, fmt.Sprintf("%s=%t", gate, enabled))
+	}
+
+	sort.Strings(gates)
+
+	fmt.Printf("core provider feature gates: %s\n", strings.Join(gates, ","))
+
+	return nil
+}
+
+func supportedFeatureGates() []string {
+	gates := make([]string, 0, len(featureGateVariables))
+
+	for gate := range featureGateVariables {
+		gates = append(gates, gate)
+	}
+
+	sort.Strings(gates)
+
+	return gates
+}
+
+// FeatureGates returns feature gates the core provider controller is running with.
+func (clusterAPI *Manager) FeatureGates(ctx context.Context) (map[string]bool, error) {
+	deployments, err := clusterAPI.providerDeployments(ctx, constants.CoreProviderName)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]bool{}
+
+	for _, deployment := range deployments {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, arg := range container.Args {
+				if !strings.HasPrefix(arg, featureGatesArg) {
+					continue
+				}
+
+				for _, gate := range strings.Split(strings.TrimPrefix(arg, featureGatesArg), ",") {
+					parts := strings.SplitN(gate, "=", 2)
+					if len(parts) != 2 {
+						continue
+					}
+
+					enabled, err := strconv.ParseBool(parts[1])
+					if err != nil {
+						return nil, fmt.Errorf("failed to parse feature gate %q %w", gate, err)
+					}
+
+					res[parts[0]] = enabled
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// checkFeatureGates warns if the installed core provider feature gates differ from the options.
+func (clusterAPI *Manager) checkFeatureGates(ctx context.Context) {
+	if len(clusterAPI.options.FeatureGates) == 0 {
+		return
+	}
+
+	actual, err := clusterAPI.FeatureGates(ctx)
+	if err != nil {
+		fmt.Printf("warning: failed to read core provider feature gates: %s\n", err)
+
+		return
+	}
+
+	for _, gate := range supportedFeatureGates() {
+		requested, ok := clusterAPI.options.FeatureGates[gate]
+		if !ok {
+			continue
+		}
+
+		if actual[gate] != requested {
+			fmt.Printf("warning: feature gate %s is %t in the options, but the core provider is running with %s=%t\n", gate, requested, gate, actual[gate])
+		}
+	}
+}
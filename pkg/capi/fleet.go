@@ -0,0 +1,117 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// waitClustersConcurrency limits the number of clusters checked at the same time.
+const waitClustersConcurrency = 10
+
+// WaitClustersReady waits for all clusters to be ready from the CAPI point of view.
+//
+// Clusters are checked concurrently, the returned aggregate error lists the clusters which failed to become ready.
+func (clusterAPI *Manager) WaitClustersReady(ctx context.Context, refs []types.NamespacedName) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+
+	sem := make(chan struct{}, waitClustersConcurrency)
+
+	for _, ref := range refs {
+		ref := ref
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s %w", ref, ctx.Err()))
+				mu.Unlock()
+
+				return
+			}
+
+			defer func() { <-sem }()
+
+			if err := clusterAPI.waitClusterReady(ctx, ref); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("cluster %s is not ready %w", ref, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return utilerrors.NewAggregate(errs)
+}
+
+func (clusterAPI *Manager) waitClusterReady(ctx context.Context, ref types.NamespacedName) error {
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		cluster, err := clusterAPI.NewCluster(ctx, ref.Name, ref.Namespace)
+		if err != nil {
+			return clusterAPI.retryable(err)
+		}
+
+		return clusterAPI.retryable(clusterAPI.CheckClusterReady(ctx, cluster))
+	})
+}
+
+// ListClustersByLabels lists clusters matching the label selector, empty namespace lists clusters in all namespaces.
+//
+// Selector is evaluated by the API server. Returned clusters are not synced, call Sync to fetch the nodes and the Talos client.
+func (clusterAPI *Manager) ListClustersByLabels(ctx context.Context, selector labels.Selector, namespace string) ([]*Cluster, error) {
+	var list unstructured.UnstructuredList
+
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	opts := []runtimeclient.ListOption{
+		runtimeclient.MatchingLabelsSelector{Selector: selector},
+	}
+
+	if namespace != "" {
+		opts = append(opts, runtimeclient.InNamespace(namespace))
+	}
+
+	if err := clusterAPI.runtimeClient.List(ctx, &list, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list clusters %w", err)
+	}
+
+	res := make([]*Cluster, 0, len(list.Items))
+
+	for _, obj := range list.Items {
+		res = append(res, &Cluster{
+			manager:   clusterAPI,
+			name:      obj.GetName(),
+			namespace: obj.GetNamespace(),
+			cluster:   obj,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,194 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+)
+
+// UpgradeImpact describes providers which have upgrades available and clusters reconciled by them.
+type UpgradeImpact struct {
+	Providers []ProviderUpgrade
+	Clusters  []AffectedCluster
+}
+
+// ProviderUpgrade is the provider which is going to be upgraded.
+type ProviderUpgrade struct {
+	// Label is the clusterctl provider label, e.g. infrastructure-aws.
+	Label            string
+	Namespace        string
+	WatchedNamespace string
+	CurrentVersion   string
+	NextVersion      string
+}
+
+// AffectedCluster is the cluster reconciled by the upgraded providers.
+//
+// Busy clusters are in the middle of provisioning, scaling or upgrade, Reason explains why.
+type AffectedCluster struct {
+	types.NamespacedName
+
+	Busy   bool
+	Reason string
+}
+
+// UpgradeImpact plans the provider upgrade for the current contract and lists clusters which would be reconciled
+// by the upgraded providers, flagging clusters which shouldn't be disturbed.
+//
+// Clusters are matched by the namespaces watched by the upgraded providers.
+func (clusterAPI *Manager) UpgradeImpact(ctx context.Context) (*UpgradeImpact, error) {
+	plans, err := clusterAPI.client.PlanUpgrade(client.PlanUpgradeOptions{Kubeconfig: clusterAPI.kubeconfig})
+	if err != nil {
+		return nil, fmt.Errorf("failed to plan providers upgrade %w", err)
+	}
+
+	impact := &UpgradeImpact{}
+
+	for _, plan := range plans {
+		if plan.Contract != clusterv1.GroupVersion.Version {
+			continue
+		}
+
+		for _, item := range plan.Providers {
+			if item.NextVersion == "" || item.NextVersion == item.Version {
+				continue
+			}
+
+			impact.Providers = append(impact.Providers, ProviderUpgrade{
+				Label:            clusterctlv1.ManifestLabel(item.ProviderName, item.GetProviderType()),
+				Namespace:        item.Namespace,
+				WatchedNamespace: item.WatchedNamespace,
+				CurrentVersion:   item.Version,
+				NextVersion:      item.NextVersion,
+			})
+		}
+	}
+
+	if len(impact.Providers) == 0 {
+		return impact, nil
+	}
+
+	var clusters unstructured.UnstructuredList
+
+	clusters.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	if err = clusterAPI.runtimeClient.List(ctx, &clusters); err != nil {
+		return nil, err
+	}
+
+	for i := range clusters.Items {
+		obj := &clusters.Items[i]
+
+		if !impact.watched(obj.GetNamespace()) {
+			continue
+		}
+
+		cluster := &Cluster{
+			manager:   clusterAPI,
+			name:      obj.GetName(),
+			namespace: obj.GetNamespace(),
+			cluster:   *obj,
+		}
+
+		reason, err := cluster.busyReason(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		impact.Clusters = append(impact.Clusters, AffectedCluster{
+			NamespacedName: types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()},
+			Busy:           reason != "",
+			Reason:         reason,
+		})
+	}
+
+	return impact, nil
+}
+
+func (impact *UpgradeImpact) watched(namespace string) bool {
+	for _, provider := range impact.Providers {
+		if provider.WatchedNamespace == "" || provider.WatchedNamespace == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// busyReason returns the reason why the cluster is in the middle of operation, empty string means the cluster is idle.
+func (cluster *Cluster) busyReason(ctx context.Context) (string, error) {
+	if cluster.cluster.GetDeletionTimestamp() != nil {
+		return "cluster is being deleted", nil
+	}
+
+	phase, _, err := unstructured.NestedString(cluster.cluster.Object, "status", "phase")
+	if err != nil {
+		return "", err
+	}
+
+	if clusterv1.ClusterPhase(phase) != clusterv1.ClusterPhaseProvisioned {
+		return fmt.Sprintf("cluster phase is %s", phase), nil
+	}
+
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	replicas, _, err := unstructured.NestedInt64(controlPlane.Object, "spec", "replicas")
+	if err != nil {
+		return "", err
+	}
+
+	currentReplicas, _, err := unstructured.NestedInt64(controlPlane.Object, "status", "replicas")
+	if err != nil {
+		return "", err
+	}
+
+	if replicas != currentReplicas {
+		return fmt.Sprintf("control plane is scaling from %d to %d replicas", currentReplicas, replicas), nil
+	}
+
+	status, err := cluster.UpgradeProgress(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if !status.Done() {
+		return fmt.Sprintf("cluster is upgrading to %s", status.TargetVersion), nil
+	}
+
+	machineDeployments, err := cluster.Workers(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, machineDeployment := range machineDeployments.Items {
+		var mdPhase string
+
+		if mdPhase, _, err = unstructured.NestedString(machineDeployment.Object, "status", "phase"); err != nil {
+			return "", err
+		}
+
+		switch clusterv1.MachineDeploymentPhase(mdPhase) {
+		case clusterv1.MachineDeploymentPhaseScalingUp, clusterv1.MachineDeploymentPhaseScalingDown:
+			return fmt.Sprintf("machine deployment %s is %s", machineDeployment.GetName(), mdPhase), nil
+		}
+	}
+
+	return "", nil
+}
@@ -10,10 +10,7 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/talos-systems/go-retry/retry"
-	v1 "k8s.io/api/apps/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
 
@@ -39,6 +36,7 @@ type AWSProvider struct {
 	ProviderVersion       string
 	ProviderNS            string
 	WatchingNS            string
+	ProviderReadyTimeout  time.Duration
 }
 
 // NewAWSSetupOptions creates new AWSSetupOptions.
@@ -104,6 +102,11 @@ func (s *AWSProvider) Name() string {
 	return constants.AWSProviderName
 }
 
+// ReadyTimeout implements ReadyTimeouter interface.
+func (s *AWSProvider) ReadyTimeout() time.Duration {
+	return s.ProviderReadyTimeout
+}
+
 // Namespace implements Provider interface.
 func (s *AWSProvider) Namespace() string {
 	return s.ProviderNS
@@ -129,24 +132,7 @@ func (s *AWSProvider) ProviderVars() (Variables, error) {
 
 // IsInstalled implements Provider interface.
 func (s *AWSProvider) IsInstalled(ctx context.Context, clientset *kubernetes.Clientset) (bool, error) {
-	_, err := clientset.CoreV1().Namespaces().Get(ctx, s.Namespace(), metav1.GetOptions{})
-	if err != nil {
-		if errors.IsNotFound(err) {
-			return false, nil
-		}
-
-		return false, err
-	}
-
-	if _, err := clientset.AppsV1().Deployments(s.Namespace()).Get(ctx, "capa-controller-master", metav1.GetOptions{}); err != nil {
-		if errors.IsNotFound(err) {
-			return false, nil
-		}
-
-		return false, err
-	}
-
-	return true, nil
+	return isDeploymentInstalled(ctx, clientset, s.Namespace(), "capa-controller-manager")
 }
 
 // ClusterVars returns config overrides for template generation.
@@ -192,24 +178,46 @@ func (s *AWSProvider) GetClusterTemplate(client client.Client, opts client.GetCl
 
 // WaitReady implements Provider interface.
 func (s *AWSProvider) WaitReady(ctx context.Context, clientset *kubernetes.Clientset) error {
-	return retry.Constant(10*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
-		if _, err := clientset.CoreV1().Namespaces().Get(ctx, s.Namespace(), metav1.GetOptions{}); err != nil {
-			return retry.ExpectedError(err)
+	return waitDeploymentReady(ctx, clientset, s.Namespace(), "capa-controller-manager")
+}
+
+// Describe implements Describer interface.
+func (s *AWSProvider) Describe(ctx context.Context, obj unstructured.Unstructured) (map[string]string, error) {
+	if obj.GroupVersionKind().Group != "infrastructure.cluster.x-k8s.io" {
+		return nil, nil
+	}
+
+	var fields map[string][]string
+
+	switch obj.GetKind() {
+	case "AWSMachine":
+		fields = map[string][]string{
+			"instanceID":   {"spec", "instanceID"},
+			"instanceType": {"spec", "instanceType"},
+			"ami":          {"spec", "ami", "id"},
+			"state":        {"status", "instanceState"},
+		}
+	case "AWSCluster":
+		fields = map[string][]string{
+			"region": {"spec", "region"},
+			"vpc":    {"spec", "network", "vpc", "id"},
 		}
+	default:
+		return nil, nil
+	}
 
-		var (
-			err        error
-			deployment *v1.Deployment
-		)
+	res := map[string]string{}
 
-		if deployment, err = clientset.AppsV1().Deployments(s.Namespace()).Get(ctx, "capa-controller-manager", metav1.GetOptions{}); err != nil {
-			return retry.ExpectedError(err)
+	for key, path := range fields {
+		value, found, err := unstructured.NestedString(obj.Object, path...)
+		if err != nil {
+			return nil, err
 		}
 
-		if deployment.Status.ReadyReplicas != deployment.Status.Replicas || deployment.Status.ReadyReplicas == 0 {
-			return retry.ExpectedError(fmt.Errorf("%d of %d replicas ready", deployment.Status.ReadyReplicas, deployment.Status.Replicas))
+		if found && value != "" {
+			res[key] = value
 		}
+	}
 
-		return nil
-	})
+	return res, nil
 }
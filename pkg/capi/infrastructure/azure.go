@@ -0,0 +1,269 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const (
+	// AzureDefaultVersion is the CAPZ version installed when no version is specified.
+	AzureDefaultVersion = "v1.2.0"
+
+	// AzureClusterIdentitySecretName is the name of the secret referenced by AzureClusterIdentity.
+	AzureClusterIdentitySecretName = "cluster-identity-secret"
+)
+
+// NewAzureProvider creates new Azure infrastructure provider.
+func NewAzureProvider(version, providerNS, watchingNS string) (*AzureProvider, error) {
+	if providerNS == "" {
+		providerNS = constants.AzureCAPZNamespace
+	}
+
+	if version == "" {
+		version = AzureDefaultVersion
+	}
+
+	return &AzureProvider{
+		ProviderVersion: version,
+		ProviderNS:      providerNS,
+		WatchingNS:      watchingNS,
+	}, nil
+}
+
+// AzureProvider infrastructure provider.
+type AzureProvider struct {
+	SubscriptionID       string
+	TenantID             string
+	ClientID             string
+	ClientSecret         string
+	ProviderVersion      string
+	ProviderNS           string
+	WatchingNS           string
+	ProviderReadyTimeout time.Duration
+}
+
+// NewAzureSetupOptions creates new AzureSetupOptions.
+func NewAzureSetupOptions() *AzureSetupOptions {
+	return &AzureSetupOptions{}
+}
+
+// AzureSetupOptions Azure specific setup options.
+type AzureSetupOptions struct {
+	AzureSubscriptionID string
+	AzureTenantID       string
+	AzureClientID       string
+	AzureClientSecret   string
+}
+
+// AzureDeployOptions defines provider specific settings for cluster deployment.
+type AzureDeployOptions struct {
+	Location                string
+	ResourceGroup           string
+	VNetName                string
+	ControlPlaneMachineType string
+	NodeMachineType         string
+}
+
+// NewAzureDeployOptions returns default deploy options for the Azure infra provider.
+func NewAzureDeployOptions() *AzureDeployOptions {
+	return &AzureDeployOptions{
+		Location:                "eastus",
+		ControlPlaneMachineType: "Standard_D2s_v3",
+		NodeMachineType:         "Standard_D2s_v3",
+	}
+}
+
+// Configure implements Provider interface.
+func (s *AzureProvider) Configure(providerOptions interface{}) error {
+	opts, ok := providerOptions.(*AzureSetupOptions)
+	if !ok {
+		return fmt.Errorf("expected AzureSetupOptions as the first argument")
+	}
+
+	s.SubscriptionID = opts.AzureSubscriptionID
+	s.TenantID = opts.AzureTenantID
+	s.ClientID = opts.AzureClientID
+	s.ClientSecret = opts.AzureClientSecret
+
+	return nil
+}
+
+// PreInstall implements PreInstaller interface.
+//
+// Credentials might be set either using setup options, credentials secret or env variables.
+func (s *AzureProvider) PreInstall(vars Variables) error {
+	for _, v := range []struct {
+		value string
+		env   string
+	}{
+		{s.SubscriptionID, "AZURE_SUBSCRIPTION_ID"},
+		{s.TenantID, "AZURE_TENANT_ID"},
+		{s.ClientID, "AZURE_CLIENT_ID"},
+		{s.ClientSecret, "AZURE_CLIENT_SECRET"},
+	} {
+		if v.value == "" && vars.Lookup(v.env) == "" {
+			return fmt.Errorf("azure credentials are not set, please set %s", v.env)
+		}
+	}
+
+	return nil
+}
+
+// Name implements Provider interface.
+func (s *AzureProvider) Name() string {
+	return constants.AzureProviderName
+}
+
+// ReadyTimeout implements ReadyTimeouter interface.
+func (s *AzureProvider) ReadyTimeout() time.Duration {
+	return s.ProviderReadyTimeout
+}
+
+// Namespace implements Provider interface.
+func (s *AzureProvider) Namespace() string {
+	return s.ProviderNS
+}
+
+// WatchingNamespace implements Provider interface.
+func (s *AzureProvider) WatchingNamespace() string {
+	return s.WatchingNS
+}
+
+// Version implements Provider interface.
+func (s *AzureProvider) Version() string {
+	return s.ProviderVersion
+}
+
+// ProviderVars returns config overrides for the provider installation.
+//
+// CAPZ components expect base64 encoded copies of the credentials.
+func (s *AzureProvider) ProviderVars() (Variables, error) {
+	vars := make(Variables)
+
+	for key, value := range map[string]string{
+		"AZURE_SUBSCRIPTION_ID": s.SubscriptionID,
+		"AZURE_TENANT_ID":       s.TenantID,
+		"AZURE_CLIENT_ID":       s.ClientID,
+		"AZURE_CLIENT_SECRET":   s.ClientSecret,
+	} {
+		if value == "" {
+			value = os.Getenv(key)
+		}
+
+		vars[key] = value
+
+		if value != "" {
+			vars[key+"_B64"] = base64.StdEncoding.EncodeToString([]byte(value))
+		}
+	}
+
+	return vars, nil
+}
+
+// IsInstalled implements Provider interface.
+func (s *AzureProvider) IsInstalled(ctx context.Context, clientset *kubernetes.Clientset) (bool, error) {
+	return isDeploymentInstalled(ctx, clientset, s.Namespace(), "capz-controller-manager")
+}
+
+// EnsureCredentials implements CredentialsEnsurer interface.
+//
+// Creates or updates the client secret referenced by AzureClusterIdentity in the provider namespace.
+func (s *AzureProvider) EnsureCredentials(ctx context.Context, clientset *kubernetes.Clientset) error {
+	clientSecret := s.ClientSecret
+	if clientSecret == "" {
+		clientSecret = os.Getenv("AZURE_CLIENT_SECRET")
+	}
+
+	// existing identity secret is kept as is if no credentials are configured
+	if clientSecret == "" {
+		return nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AzureClusterIdentitySecretName,
+			Namespace: s.Namespace(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"clientSecret": []byte(clientSecret),
+		},
+	}
+
+	_, err := clientset.CoreV1().Secrets(s.Namespace()).Create(ctx, secret, metav1.CreateOptions{})
+	if err == nil || !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	existing, err := clientset.CoreV1().Secrets(s.Namespace()).Get(ctx, AzureClusterIdentitySecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if string(existing.Data["clientSecret"]) == clientSecret {
+		return nil
+	}
+
+	if existing.Data == nil {
+		existing.Data = map[string][]byte{}
+	}
+
+	existing.Data["clientSecret"] = []byte(clientSecret)
+
+	_, err = clientset.CoreV1().Secrets(s.Namespace()).Update(ctx, existing, metav1.UpdateOptions{})
+
+	return err
+}
+
+// ClusterVars returns config overrides for template generation.
+func (s *AzureProvider) ClusterVars(opts interface{}) (Variables, error) {
+	var (
+		deployOptions = NewAzureDeployOptions()
+		ok            bool
+	)
+
+	if opts != nil {
+		deployOptions, ok = opts.(*AzureDeployOptions)
+		if !ok {
+			return nil, fmt.Errorf("azure deployment provider expects azure.DeployOptions as the deployment options")
+		}
+	}
+
+	vars := Variables{
+		"AZURE_LOCATION":                   deployOptions.Location,
+		"AZURE_RESOURCE_GROUP":             deployOptions.ResourceGroup,
+		"AZURE_VNET_NAME":                  deployOptions.VNetName,
+		"AZURE_CONTROL_PLANE_MACHINE_TYPE": deployOptions.ControlPlaneMachineType,
+		"AZURE_NODE_MACHINE_TYPE":          deployOptions.NodeMachineType,
+
+		"AZURE_CLUSTER_IDENTITY_SECRET_NAME":      AzureClusterIdentitySecretName,
+		"AZURE_CLUSTER_IDENTITY_SECRET_NAMESPACE": s.Namespace(),
+	}
+
+	return vars, nil
+}
+
+// GetClusterTemplate implements Provider interface.
+func (s *AzureProvider) GetClusterTemplate(client client.Client, opts client.GetClusterTemplateOptions) (client.Template, error) {
+	return client.GetClusterTemplate(opts)
+}
+
+// WaitReady implements Provider interface.
+func (s *AzureProvider) WaitReady(ctx context.Context, clientset *kubernetes.Clientset) error {
+	return waitDeploymentReady(ctx, clientset, s.Namespace(), "capz-controller-manager")
+}
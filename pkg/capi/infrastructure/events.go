@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DeploymentWarning returns the most recent Warning event of the deployment or its pods,
+// e.g. ImagePullBackOff or FailedScheduling, empty string is returned if there are no warnings.
+//
+// Pod events are preferred, as they usually explain why the deployment is not progressing.
+func DeploymentWarning(ctx context.Context, clientset *kubernetes.Clientset, deployment *appsv1.Deployment) (string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	pods, err := clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return "", err
+	}
+
+	var latest *corev1.Event
+
+	for _, pod := range pods.Items {
+		if latest, err = latestWarning(ctx, clientset, deployment.Namespace, "Pod", pod.Name, latest); err != nil {
+			return "", err
+		}
+	}
+
+	if latest == nil {
+		if latest, err = latestWarning(ctx, clientset, deployment.Namespace, "Deployment", deployment.Name, nil); err != nil {
+			return "", err
+		}
+	}
+
+	if latest == nil {
+		return "", nil
+	}
+
+	return fmt.Sprintf("%s %s: %s: %s", latest.InvolvedObject.Kind, latest.InvolvedObject.Name, latest.Reason, latest.Message), nil
+}
+
+// withDeploymentWarning appends the deployment warning to the error if there is any.
+func withDeploymentWarning(ctx context.Context, clientset *kubernetes.Clientset, deployment *appsv1.Deployment, err error) error {
+	warning, e := DeploymentWarning(ctx, clientset, deployment)
+	if e != nil || warning == "" {
+		return err
+	}
+
+	return fmt.Errorf("%w, last warning: %s", err, warning)
+}
+
+func latestWarning(ctx context.Context, clientset *kubernetes.Clientset, namespace, kind, name string, latest *corev1.Event) (*corev1.Event, error) {
+	events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.Set{
+			"involvedObject.kind": kind,
+			"involvedObject.name": name,
+			"type":                corev1.EventTypeWarning,
+		}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range events.Items {
+		event := &events.Items[i]
+
+		if latest == nil || eventTime(event).After(eventTime(latest)) {
+			latest = event
+		}
+	}
+
+	return latest, nil
+}
+
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
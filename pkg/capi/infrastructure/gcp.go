@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+// GCPDefaultVersion is the CAPG version installed when no version is specified.
+const GCPDefaultVersion = "v1.0.1"
+
+// NewGCPProvider creates new GCP infrastructure provider.
+func NewGCPProvider(version, providerNS, watchingNS string) (*GCPProvider, error) {
+	if providerNS == "" {
+		providerNS = constants.GCPCAPGNamespace
+	}
+
+	if version == "" {
+		version = GCPDefaultVersion
+	}
+
+	return &GCPProvider{
+		ProviderVersion: version,
+		ProviderNS:      providerNS,
+		WatchingNS:      watchingNS,
+	}, nil
+}
+
+// GCPProvider infrastructure provider.
+type GCPProvider struct {
+	B64EncodedCredentials string
+	Project               string
+	ProviderVersion       string
+	ProviderNS            string
+	WatchingNS            string
+	ProviderReadyTimeout  time.Duration
+}
+
+// NewGCPSetupOptions creates new GCPSetupOptions.
+func NewGCPSetupOptions() *GCPSetupOptions {
+	return &GCPSetupOptions{}
+}
+
+// GCPSetupOptions GCP specific setup options.
+type GCPSetupOptions struct {
+	GCPCredentials string
+	GCPProject     string
+}
+
+// GCPDeployOptions defines provider specific settings for cluster deployment.
+type GCPDeployOptions struct {
+	Project                 string
+	Region                  string
+	Network                 string
+	ControlPlaneMachineType string
+	NodeMachineType         string
+	ImageID                 string
+}
+
+// NewGCPDeployOptions returns default deploy options for the GCP infra provider.
+func NewGCPDeployOptions() *GCPDeployOptions {
+	return &GCPDeployOptions{
+		Network:                 "default",
+		ControlPlaneMachineType: "n1-standard-2",
+		NodeMachineType:         "n1-standard-2",
+	}
+}
+
+// Configure implements Provider interface.
+func (s *GCPProvider) Configure(providerOptions interface{}) error {
+	opts, ok := providerOptions.(*GCPSetupOptions)
+	if !ok {
+		return fmt.Errorf("expected GCPSetupOptions as the first argument")
+	}
+
+	s.B64EncodedCredentials = opts.GCPCredentials
+	s.Project = opts.GCPProject
+
+	return nil
+}
+
+// PreInstall implements PreInstaller interface.
+//
+// Credentials and project might be set either using setup options, credentials secret or env variables.
+func (s *GCPProvider) PreInstall(vars Variables) error {
+	if s.Project == "" && vars.Lookup("GCP_PROJECT") == "" {
+		return fmt.Errorf("GCP project is not set, please set GCP_PROJECT")
+	}
+
+	if s.B64EncodedCredentials == "" && vars.Lookup("GCP_B64ENCODED_CREDENTIALS") == "" {
+		return fmt.Errorf("GCP credentials are not set, please set GCP_B64ENCODED_CREDENTIALS")
+	}
+
+	return nil
+}
+
+// Name implements Provider interface.
+func (s *GCPProvider) Name() string {
+	return constants.GCPProviderName
+}
+
+// ReadyTimeout implements ReadyTimeouter interface.
+func (s *GCPProvider) ReadyTimeout() time.Duration {
+	return s.ProviderReadyTimeout
+}
+
+// Namespace implements Provider interface.
+func (s *GCPProvider) Namespace() string {
+	return s.ProviderNS
+}
+
+// WatchingNamespace implements Provider interface.
+func (s *GCPProvider) WatchingNamespace() string {
+	return s.WatchingNS
+}
+
+// Version implements Provider interface.
+func (s *GCPProvider) Version() string {
+	return s.ProviderVersion
+}
+
+// ProviderVars returns config overrides for the provider installation.
+func (s *GCPProvider) ProviderVars() (Variables, error) {
+	vars := make(Variables)
+	vars["GCP_B64ENCODED_CREDENTIALS"] = s.B64EncodedCredentials
+	vars["GCP_PROJECT"] = s.Project
+
+	return vars, nil
+}
+
+// IsInstalled implements Provider interface.
+func (s *GCPProvider) IsInstalled(ctx context.Context, clientset *kubernetes.Clientset) (bool, error) {
+	return isDeploymentInstalled(ctx, clientset, s.Namespace(), "capg-controller-manager")
+}
+
+// ClusterVars returns config overrides for template generation.
+func (s *GCPProvider) ClusterVars(opts interface{}) (Variables, error) {
+	var (
+		deployOptions = NewGCPDeployOptions()
+		ok            bool
+	)
+
+	if opts != nil {
+		deployOptions, ok = opts.(*GCPDeployOptions)
+		if !ok {
+			return nil, fmt.Errorf("GCP deployment provider expects gcp.DeployOptions as the deployment options")
+		}
+	}
+
+	project := deployOptions.Project
+	if project == "" {
+		project = s.Project
+	}
+
+	vars := Variables{
+		"GCP_PROJECT":                    project,
+		"GCP_REGION":                     deployOptions.Region,
+		"GCP_NETWORK_NAME":               deployOptions.Network,
+		"GCP_CONTROL_PLANE_MACHINE_TYPE": deployOptions.ControlPlaneMachineType,
+		"GCP_NODE_MACHINE_TYPE":          deployOptions.NodeMachineType,
+		"IMAGE_ID":                       deployOptions.ImageID,
+	}
+
+	return vars, nil
+}
+
+// GetClusterTemplate implements Provider interface.
+func (s *GCPProvider) GetClusterTemplate(client client.Client, opts client.GetClusterTemplateOptions) (client.Template, error) {
+	return client.GetClusterTemplate(opts)
+}
+
+// WaitReady implements Provider interface.
+func (s *GCPProvider) WaitReady(ctx context.Context, clientset *kubernetes.Clientset) error {
+	return waitDeploymentReady(ctx, clientset, s.Namespace(), "capg-controller-manager")
+}
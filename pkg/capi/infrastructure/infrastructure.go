@@ -8,14 +8,24 @@ package infrastructure
 import (
 	"context"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/talos-systems/go-retry/retry"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
 
 	"github.com/talos-systems/capi-utils/pkg/constants"
 )
 
+// defaultReadyTimeout is the WaitReady timeout if neither the provider nor the global timeout is set.
+const defaultReadyTimeout = 10 * time.Minute
+
 // Variables is a map of key value pairs of config parameters.
 type Variables map[string]string
 
@@ -24,6 +34,9 @@ type Provider interface {
 	Name() string
 	Namespace() string
 	Version() string
+	// WatchingNamespace returns the namespace provider controller reconciles objects in, empty means all namespaces.
+	// It is reconciled on every install, clusterctl supports a single instance of the provider in the management cluster,
+	// so the same provider can't be scoped to several namespaces.
 	WatchingNamespace() string
 	Configure(interface{}) error
 	ProviderVars() (Variables, error)
@@ -33,10 +46,63 @@ type Provider interface {
 	WaitReady(context.Context, *kubernetes.Clientset) error
 }
 
+// Dependent is implemented by the providers which should be installed after other infrastructure providers.
+type Dependent interface {
+	// DependsOn returns names of the infrastructure providers which should be installed first.
+	DependsOn() []string
+}
+
+// PreInstaller is implemented by the providers which check their environment before the install,
+// e.g. that the credentials are set.
+//
+// Variables are read from the credentials secret, they take precedence over the env variables.
+type PreInstaller interface {
+	PreInstall(vars Variables) error
+}
+
+// Lookup returns the variable value falling back to the env variable.
+func (vars Variables) Lookup(key string) string {
+	if value := vars[key]; value != "" {
+		return value
+	}
+
+	return os.Getenv(key)
+}
+
+// CredentialsEnsurer is implemented by the providers which keep the credentials in the management cluster
+// in sync with the configured ones, it is called on every install.
+type CredentialsEnsurer interface {
+	EnsureCredentials(ctx context.Context, clientset *kubernetes.Clientset) error
+}
+
+// ReadyTimeouter is implemented by the providers which need a specific WaitReady timeout.
+type ReadyTimeouter interface {
+	// ReadyTimeout returns how long WaitReady is allowed to take, zero means the global provider wait timeout.
+	ReadyTimeout() time.Duration
+}
+
+// ReadyTimeout returns the provider WaitReady timeout, zero means the global provider wait timeout.
+func ReadyTimeout(provider Provider) time.Duration {
+	if timeouter, ok := provider.(ReadyTimeouter); ok {
+		return timeouter.ReadyTimeout()
+	}
+
+	return 0
+}
+
+// Describer is implemented by the providers which can report provider-specific details of their objects.
+//
+// Describe is called for every infrastructure object of the cluster, nil should be returned for the objects
+// of the other providers.
+type Describer interface {
+	Describe(ctx context.Context, obj unstructured.Unstructured) (map[string]string, error)
+}
+
 // ProviderOptions is the functional options struct.
 type ProviderOptions struct {
-	ProviderNS string
-	WatchingNS string
+	ProviderNS   string
+	WatchingNS   string
+	ReadyTimeout time.Duration
 }
 
 // ProviderOption is the functional options func.
@@ -56,6 +122,22 @@ func WithWatchingNS(ns string) ProviderOption {
 	}
 }
 
+// WithReadyTimeout overrides the global provider wait timeout for the provider.
+func WithReadyTimeout(timeout time.Duration) ProviderOption {
+	return func(opts *ProviderOptions) {
+		opts.ReadyTimeout = timeout
+	}
+}
+
+// SupportedProviders returns sorted names of the providers NewProvider can create.
+func SupportedProviders() []string {
+	return []string{
+		constants.AWSProviderName,
+		constants.AzureProviderName,
+		constants.GCPProviderName,
+	}
+}
+
 // NewProvider creates a new provider from a specified type.
 func NewProvider(providerType string, opts ...ProviderOption) (Provider, error) {
 	// Handle any functional options
@@ -74,13 +156,122 @@ func NewProvider(providerType string, opts ...ProviderOption) (Provider, error)
 		version = parts[1]
 	}
 
-	if parts[0] == constants.AWSProviderName {
-		return NewAWSProvider(
+	switch parts[0] {
+	case constants.AWSProviderName:
+		provider, err := NewAWSProvider(
+			version,
+			providerOpts.ProviderNS,
+			providerOpts.WatchingNS,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		provider.ProviderReadyTimeout = providerOpts.ReadyTimeout
+
+		return provider, nil
+	case constants.GCPProviderName:
+		provider, err := NewGCPProvider(
+			version,
+			providerOpts.ProviderNS,
+			providerOpts.WatchingNS,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		provider.ProviderReadyTimeout = providerOpts.ReadyTimeout
+
+		return provider, nil
+	case constants.AzureProviderName:
+		provider, err := NewAzureProvider(
 			version,
 			providerOpts.ProviderNS,
 			providerOpts.WatchingNS,
 		)
+		if err != nil {
+			return nil, err
+		}
+
+		provider.ProviderReadyTimeout = providerOpts.ReadyTimeout
+
+		return provider, nil
 	}
 
-	return nil, fmt.Errorf("unknown infrastructure provider type %s", parts[0])
+	return nil, fmt.Errorf("unknown infrastructure provider type %s, supported providers: %s", parts[0], strings.Join(SupportedProviders(), ", "))
+}
+
+func isDeploymentInstalled(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) (bool, error) {
+	_, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if _, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+type retryableErrorFuncKey struct{}
+
+// WithRetryableErrorFunc returns the context which makes WaitReady classify the API errors
+// as transient (retried) or fatal with isRetryable.
+//
+// By default all errors are retried until the context deadline.
+func WithRetryableErrorFunc(ctx context.Context, isRetryable func(error) bool) context.Context {
+	return context.WithValue(ctx, retryableErrorFuncKey{}, isRetryable)
+}
+
+// retryable marks the error as expected if it's transient according to the context classifier.
+//
+// Not found errors are always retried, as the provider objects might not be created yet.
+func retryable(ctx context.Context, err error) error {
+	isRetryable, ok := ctx.Value(retryableErrorFuncKey{}).(func(error) bool)
+
+	if !ok || isRetryable == nil || errors.IsNotFound(err) || isRetryable(err) {
+		return retry.ExpectedError(err)
+	}
+
+	return err
+}
+
+func waitDeploymentReady(ctx context.Context, clientset *kubernetes.Clientset, namespace, name string) error {
+	timeout := defaultReadyTimeout
+
+	// the caller limits the wait with the provider ready timeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	return retry.Constant(timeout, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		if _, err := clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err != nil {
+			return retryable(ctx, err)
+		}
+
+		var (
+			err        error
+			deployment *v1.Deployment
+		)
+
+		if deployment, err = clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{}); err != nil {
+			return retryable(ctx, err)
+		}
+
+		if deployment.Status.ReadyReplicas != deployment.Status.Replicas || deployment.Status.ReadyReplicas == 0 {
+			return retry.ExpectedError(withDeploymentWarning(ctx, clientset, deployment,
+				fmt.Errorf("%d of %d replicas ready", deployment.Status.ReadyReplicas, deployment.Status.Replicas)))
+		}
+
+		return nil
+	})
 }
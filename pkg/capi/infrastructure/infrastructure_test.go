@@ -0,0 +1,67 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRetryable(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "deployments"}, "capa-controller-manager", errors.New("denied"))
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "deployments"}, "capa-controller-manager")
+	never := func(error) bool { return false }
+
+	for _, tt := range []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{
+			name:     "no classifier",
+			ctx:      context.Background(),
+			err:      forbidden,
+			expected: true,
+		},
+		{
+			name:     "fatal",
+			ctx:      WithRetryableErrorFunc(context.Background(), never),
+			err:      forbidden,
+			expected: false,
+		},
+		{
+			name:     "not found",
+			ctx:      WithRetryableErrorFunc(context.Background(), never),
+			err:      notFound,
+			expected: true,
+		},
+		{
+			name:     "transient",
+			ctx:      WithRetryableErrorFunc(context.Background(), func(err error) bool { return apierrors.IsForbidden(err) }),
+			err:      forbidden,
+			expected: true,
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			res := retryable(tt.ctx, tt.err)
+
+			// expected errors are wrapped, fatal ones are returned as is
+			if wrapped := res != tt.err; wrapped != tt.expected { //nolint:errorlint
+				t.Errorf("expected retryable %v, got %v", tt.expected, wrapped)
+			}
+
+			if !errors.Is(res, tt.err) {
+				t.Errorf("error %v doesn't wrap %v", res, tt.err)
+			}
+		})
+	}
+}
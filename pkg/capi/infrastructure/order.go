@@ -0,0 +1,76 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InstallOrder sorts providers so that each provider goes after the providers it depends on, see Dependent.
+//
+// Providers without dependencies between them keep their original order.
+// Dependencies which are not in the list are expected to be installed already and are ignored.
+func InstallOrder(providers []Provider) ([]Provider, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	byName := make(map[string]Provider, len(providers))
+
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	state := make(map[string]int, len(providers))
+	res := make([]Provider, 0, len(providers))
+
+	var visit func(provider Provider, path []string) error
+
+	visit = func(provider Provider, path []string) error {
+		name := provider.Name()
+		path = append(path, name)
+
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("infrastructure provider dependency cycle detected: %s", strings.Join(path, " -> "))
+		}
+
+		state[name] = visiting
+
+		var dependencies []string
+
+		if dependent, ok := provider.(Dependent); ok {
+			dependencies = dependent.DependsOn()
+		}
+
+		for _, dependency := range dependencies {
+			dep, ok := byName[dependency]
+			if !ok {
+				continue
+			}
+
+			if err := visit(dep, path); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		res = append(res, provider)
+
+		return nil
+	}
+
+	for _, provider := range providers {
+		if err := visit(provider, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
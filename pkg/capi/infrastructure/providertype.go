@@ -0,0 +1,74 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/config"
+)
+
+// ProviderType returns the type of the provider by its name using the clusterctl built-in providers list.
+//
+// Provider might be either a name or name:version. Names which are used by several provider types,
+// e.g. talos bootstrap and control plane providers, and the names unknown to clusterctl return an error.
+func ProviderType(provider string) (clusterctlv1.ProviderType, error) {
+	name, _, err := ParseProvider(provider)
+	if err != nil {
+		return "", err
+	}
+
+	types, err := ProviderTypes(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(types) {
+	case 0:
+		return "", fmt.Errorf("unknown provider %q", name)
+	case 1:
+		return types[0], nil
+	}
+
+	names := make([]string, 0, len(types))
+
+	for _, providerType := range types {
+		names = append(names, string(providerType))
+	}
+
+	return "", fmt.Errorf("provider %q type is ambiguous, it might be %s", name, strings.Join(names, " or "))
+}
+
+// ProviderTypes returns all types of the clusterctl built-in providers with the name.
+//
+// Provider might be either a name or name:version, empty list is returned for the names unknown to clusterctl.
+func ProviderTypes(provider string) ([]clusterctlv1.ProviderType, error) {
+	name, _, err := ParseProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+
+	configClient, err := config.New("", config.InjectReader(config.NewMemoryReader()))
+	if err != nil {
+		return nil, err
+	}
+
+	providers, err := configClient.Providers().List()
+	if err != nil {
+		return nil, err
+	}
+
+	var types []clusterctlv1.ProviderType
+
+	for _, p := range providers {
+		if p.Name() == name {
+			types = append(types, p.Type())
+		}
+	}
+
+	return types, nil
+}
@@ -0,0 +1,112 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// ParseProvider parses name or name:version provider string.
+//
+// Version is either an exact semantic version or a version range, e.g. metal:>=0.5.0 <0.6.0.
+func ParseProvider(provider string) (name, providerVersion string, err error) {
+	parts := strings.Split(provider, ":")
+
+	if len(parts) > 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("malformed provider %q, expected name or name:version", provider)
+	}
+
+	if len(parts) == 2 {
+		if err = ValidateVersion(parts[1]); err != nil {
+			return "", "", fmt.Errorf("malformed provider %q version %w", provider, err)
+		}
+
+		providerVersion = parts[1]
+	}
+
+	return parts[0], providerVersion, nil
+}
+
+// ValidateVersion checks that the provider version is either a semantic version or a version range.
+func ValidateVersion(providerVersion string) error {
+	if !IsVersionRange(providerVersion) {
+		return nil
+	}
+
+	if _, err := semver.NewConstraint(providerVersion); err != nil {
+		return fmt.Errorf("%q is neither a semantic version nor a version range %w", providerVersion, err)
+	}
+
+	return nil
+}
+
+// IsVersionRange returns true if the provider version is not an exact semantic version.
+func IsVersionRange(providerVersion string) bool {
+	if providerVersion == "" {
+		return false
+	}
+
+	_, err := version.ParseSemantic(providerVersion)
+
+	return err != nil
+}
+
+// MatchVersion checks if the version satisfies the requested version or version range.
+func MatchVersion(requested, providerVersion string) (bool, error) {
+	if !IsVersionRange(requested) {
+		return requested == providerVersion, nil
+	}
+
+	constraint, err := semver.NewConstraint(requested)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := semver.NewVersion(providerVersion)
+	if err != nil {
+		return false, err
+	}
+
+	return constraint.Check(v), nil
+}
+
+// ResolveVersion picks the highest version matching the version range, pre-releases are ignored.
+func ResolveVersion(requested string, versions []string) (string, error) {
+	constraint, err := semver.NewConstraint(requested)
+	if err != nil {
+		return "", err
+	}
+
+	var latest *semver.Version
+
+	res := ""
+
+	for _, s := range versions {
+		var v *semver.Version
+
+		if v, err = semver.NewVersion(s); err != nil || v.Prerelease() != "" {
+			continue
+		}
+
+		if !constraint.Check(v) {
+			continue
+		}
+
+		if latest == nil || latest.LessThan(v) {
+			latest = v
+			res = s
+		}
+	}
+
+	if res == "" {
+		return "", fmt.Errorf("no published version matches %q", requested)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,141 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package infrastructure
+
+import "testing"
+
+func TestParseProvider(t *testing.T) {
+	for _, tt := range []struct {
+		provider string
+		name     string
+		version  string
+		err      bool
+	}{
+		{provider: "aws", name: "aws"},
+		{provider: "aws:v1.2.0", name: "aws", version: "v1.2.0"},
+		{provider: "metal:>=0.5.0 <0.6.0", name: "metal", version: ">=0.5.0 <0.6.0"},
+		{provider: "metal:~0.5", name: "metal", version: "~0.5"},
+		{provider: "metal:>=latest", err: true},
+		{provider: ":v1.2.0", err: true},
+		{provider: "aws:v1.2.0:extra", err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.provider, func(t *testing.T) {
+			name, version, err := ParseProvider(tt.provider)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q %q", name, version)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if name != tt.name || version != tt.version {
+				t.Errorf("expected %q %q, got %q %q", tt.name, tt.version, name, version)
+			}
+		})
+	}
+}
+
+func TestValidateVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		isRange bool
+		err     bool
+	}{
+		{version: ""},
+		{version: "v0.5.1"},
+		{version: "v0.5.1-alpha.0"},
+		{version: ">=0.5.0 <0.6.0", isRange: true},
+		{version: "0.5.x", isRange: true},
+		{version: ">=>0.5", isRange: true, err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.version, func(t *testing.T) {
+			if isRange := IsVersionRange(tt.version); isRange != tt.isRange {
+				t.Errorf("expected version range %v, got %v", tt.isRange, isRange)
+			}
+
+			if err := ValidateVersion(tt.version); (err != nil) != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		requested string
+		version   string
+		match     bool
+		err       bool
+	}{
+		{name: "exact", requested: "v0.5.1", version: "v0.5.1", match: true},
+		{name: "exact mismatch", requested: "v0.5.1", version: "v0.5.2"},
+		{name: "range", requested: ">=0.5.0 <0.6.0", version: "v0.5.3", match: true},
+		{name: "range upper bound", requested: ">=0.5.0 <0.6.0", version: "v0.6.0"},
+		{name: "range lower bound", requested: ">=0.5.0 <0.6.0", version: "v0.4.9"},
+		{name: "malformed range", requested: ">=>0.5", version: "v0.5.3", err: true},
+		{name: "malformed version", requested: ">=0.5.0", version: "latest", err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := MatchVersion(tt.requested, tt.version)
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if match != tt.match {
+				t.Errorf("expected match %v, got %v", tt.match, match)
+			}
+		})
+	}
+}
+
+func TestResolveVersion(t *testing.T) {
+	published := []string{"v0.4.9", "v0.5.3", "v0.5.1", "v0.5.4-alpha.1", "v0.6.0", "not-a-version"}
+
+	for _, tt := range []struct {
+		name      string
+		requested string
+		expected  string
+		err       bool
+	}{
+		{name: "highest release in range", requested: ">=0.5.0 <0.6.0", expected: "v0.5.3"},
+		{name: "tilde", requested: "~0.4", expected: "v0.4.9"},
+		{name: "open range", requested: ">=0.5.0", expected: "v0.6.0"},
+		{name: "no published version", requested: ">=0.7.0", err: true},
+		{name: "malformed range", requested: ">=>0.5", err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := ResolveVersion(tt.requested, published)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", version)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, version)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	clientcmd "k8s.io/client-go/tools/clientcmd"
+	capiclient "sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+)
+
+// KubeconfigOptions defines additional optional parameters for workload kubeconfig generation.
+type KubeconfigOptions struct {
+	Server string
+	CAData []byte
+	Ping   bool
+}
+
+// KubeconfigOption optional kubeconfig parameter setter.
+type KubeconfigOption func(*KubeconfigOptions)
+
+// WithServer overrides workload cluster API server URL, e.g. to access it through an internal load balancer.
+func WithServer(server string) KubeconfigOption {
+	return func(opts *KubeconfigOptions) {
+		opts.Server = server
+	}
+}
+
+// WithCAData overrides workload cluster API server CA certificate.
+func WithCAData(data []byte) KubeconfigOption {
+	return func(opts *KubeconfigOptions) {
+		opts.CAData = data
+	}
+}
+
+// WithPing verifies that the resulting kubeconfig can reach the workload cluster API server.
+func WithPing() KubeconfigOption {
+	return func(opts *KubeconfigOptions) {
+		opts.Ping = true
+	}
+}
+
+// GetWorkloadKubeconfig returns kubeconfig of the workload cluster.
+func (cluster *Cluster) GetWorkloadKubeconfig(ctx context.Context, setters ...KubeconfigOption) ([]byte, error) {
+	var opts KubeconfigOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	kubeconfig, err := cluster.manager.GetKubeconfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := cluster.manager.client.GetKubeconfig(capiclient.GetKubeconfigOptions{
+		Kubeconfig:          kubeconfig,
+		WorkloadClusterName: cluster.name,
+		Namespace:           cluster.namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data := []byte(raw)
+
+	if opts.Server != "" || opts.CAData != nil {
+		config, err := clientcmd.Load(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range config.Clusters {
+			if opts.Server != "" {
+				c.Server = opts.Server
+			}
+
+			if opts.CAData != nil {
+				c.CertificateAuthority = ""
+				c.CertificateAuthorityData = opts.CAData
+			}
+		}
+
+		if data, err = clientcmd.Write(*config); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Ping {
+		config, err := clientcmd.RESTConfigFromKubeConfig(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = pingAPIServer(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// pingAPIServer checks that the API server is reachable using the config.
+func pingAPIServer(ctx context.Context, config *rest.Config) error {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	if err = clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("failed to reach API server %s %w", config.Host, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/version"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/config"
+	"sigs.k8s.io/yaml"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const (
+	localMetadataFile   = "metadata.yaml"
+	localComponentsFile = "components.yaml"
+)
+
+// configureLocalProviders points clusterctl provider repositories to the local directories.
+//
+// Local directories should follow clusterctl layout: {basepath}/{provider-label}/{version}/.
+func (clusterAPI *Manager) configureLocalProviders(paths map[string]string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var providers []map[string]interface{}
+
+	if err := clusterAPI.cfg.UnmarshalKey(config.ProvidersConfigKey, &providers); err != nil {
+		return err
+	}
+
+	for label, dir := range paths {
+		name, providerType, err := parseProviderLabel(label)
+		if err != nil {
+			return err
+		}
+
+		if dir, err = filepath.Abs(dir); err != nil {
+			return err
+		}
+
+		if err = validateLocalProvider(label, dir, clusterAPI.requestedVersion(name, providerType)); err != nil {
+			return err
+		}
+
+		filtered := make([]map[string]interface{}, 0, len(providers))
+
+		for _, p := range providers {
+			if p["name"] == name && p["type"] == string(providerType) {
+				continue
+			}
+
+			filtered = append(filtered, p)
+		}
+
+		providers = append(filtered, map[string]interface{}{
+			"name": name,
+			"url":  filepath.Join(dir, localComponentsFile),
+			"type": string(providerType),
+		})
+	}
+
+	clusterAPI.cfg.config.Set(config.ProvidersConfigKey, providers)
+
+	return nil
+}
+
+// requestedVersion returns provider version set in the options, empty string means latest.
+//
+// Version ranges are returned as is until they are resolved by the install.
+func (clusterAPI *Manager) requestedVersion(name string, providerType clusterctlv1.ProviderType) string {
+	var requested []string
+
+	switch providerType { //nolint:exhaustive
+	case clusterctlv1.CoreProviderType:
+		requested = []string{clusterAPI.options.CoreProvider}
+	case clusterctlv1.BootstrapProviderType:
+		requested = clusterAPI.options.BootstrapProviders
+	case clusterctlv1.ControlPlaneProviderType:
+		requested = clusterAPI.options.ControlPlaneProviders
+	case clusterctlv1.InfrastructureProviderType:
+		if v, ok := clusterAPI.resolvedVersions[name]; ok {
+			return v
+		}
+
+		for _, provider := range clusterAPI.options.InfrastructureProviders {
+			if provider.Name() == name {
+				return provider.Version()
+			}
+		}
+	}
+
+	for _, provider := range requested {
+		parts := strings.Split(provider, ":")
+
+		if parts[0] == name && len(parts) > 1 {
+			return parts[1]
+		}
+	}
+
+	return ""
+}
+
+func parseProviderLabel(label string) (string, clusterctlv1.ProviderType, error) {
+	if label == constants.CoreProviderName {
+		return label, clusterctlv1.CoreProviderType, nil
+	}
+
+	for _, providerType := range []clusterctlv1.ProviderType{
+		clusterctlv1.BootstrapProviderType,
+		clusterctlv1.ControlPlaneProviderType,
+		clusterctlv1.InfrastructureProviderType,
+	} {
+		prefix := clusterctlv1.ManifestLabel("", providerType)
+
+		if strings.HasPrefix(label, prefix) && len(label) > len(prefix) {
+			return strings.TrimPrefix(label, prefix), providerType, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("failed to parse provider label %q, expected cluster-api, bootstrap-<name>, control-plane-<name> or infrastructure-<name>", label)
+}
+
+func validateLocalProvider(label, dir, requestedVersion string) error {
+	for _, file := range []string{localMetadataFile, localComponentsFile} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err != nil {
+			return fmt.Errorf("local provider %s is missing %s %w", label, file, err)
+		}
+	}
+
+	dirVersion := filepath.Base(dir)
+
+	if parent := filepath.Base(filepath.Dir(dir)); parent != label {
+		return fmt.Errorf("local provider %s directory %s should be in the form {basepath}/%s/{version}", label, dir, label)
+	}
+
+	if requestedVersion != "" {
+		matches, err := infrastructure.MatchVersion(requestedVersion, dirVersion)
+		if err != nil {
+			return fmt.Errorf("local provider %s directory should be named after the provider version %w", label, err)
+		}
+
+		if !matches {
+			return fmt.Errorf("local provider %s has version %s, but %s is requested", label, dirVersion, requestedVersion)
+		}
+	}
+
+	v, err := version.ParseSemantic(dirVersion)
+	if err != nil {
+		return fmt.Errorf("local provider %s directory should be named after the provider version %w", label, err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, localMetadataFile))
+	if err != nil {
+		return err
+	}
+
+	var metadata clusterctlv1.Metadata
+
+	if err = yaml.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("failed to parse local provider %s metadata %w", label, err)
+	}
+
+	if metadata.GetReleaseSeriesForVersion(v) == nil {
+		return fmt.Errorf("local provider %s metadata has no release series for version %s", label, dirVersion)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+)
+
+// logStreamsRestartInterval is the delay before the controller pods are listed again once all log streams end.
+const logStreamsRestartInterval = 5 * time.Second
+
+// WaitForLogPattern follows the provider controller logs and returns once a log line matches the regular expression.
+//
+// Provider is either the infrastructure provider name, e.g. aws, or the clusterctl label, e.g. bootstrap-talos.
+// Only the lines logged after the call are matched. Logs of the restarted controller pods are followed as well.
+func (clusterAPI *Manager) WaitForLogPattern(ctx context.Context, providerName string, re *regexp.Regexp) error {
+	label := providerName
+
+	if _, _, err := parseProviderLabel(providerName); err != nil {
+		label = clusterctlv1.ManifestLabel(providerName, clusterctlv1.InfrastructureProviderType)
+	}
+
+	since := metav1.Now()
+
+	for {
+		matched, err := clusterAPI.followProviderLogs(ctx, label, since, re)
+		if err != nil {
+			return err
+		}
+
+		if matched {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("provider %s didn't log a line matching %q %w", label, re, ctx.Err())
+		case <-time.After(logStreamsRestartInterval):
+		}
+	}
+}
+
+// followProviderLogs follows the logs of the running provider controller containers until a line matches or all streams end.
+func (clusterAPI *Manager) followProviderLogs(ctx context.Context, label string, since metav1.Time, re *regexp.Regexp) (bool, error) {
+	deployments, err := clusterAPI.providerDeployments(ctx, label)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var (
+		wg      sync.WaitGroup
+		matched = make(chan struct{}, 1)
+	)
+
+	// streams are closed by the context cancel
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for _, deployment := range deployments {
+		var (
+			selector labels.Selector
+			pods     *corev1.PodList
+		)
+
+		if selector, err = metav1.LabelSelectorAsSelector(deployment.Spec.Selector); err != nil {
+			return false, err
+		}
+
+		pods, err = clusterAPI.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return false, err
+		}
+
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+
+			for _, container := range pod.Spec.Containers {
+				if len(pod.Spec.Containers) > 1 && container.Name != providerManagerContainer {
+					continue
+				}
+
+				var logs io.ReadCloser
+
+				logs, err = clusterAPI.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+					Container: container.Name,
+					Follow:    true,
+					SinceTime: &since,
+				}).Stream(ctx)
+				if err != nil {
+					return false, fmt.Errorf("failed to follow pod %s/%s logs %w", pod.Namespace, pod.Name, err)
+				}
+
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+					defer logs.Close() //nolint:errcheck
+
+					scanner := bufio.NewScanner(logs)
+					scanner.Buffer(nil, 1024*1024)
+
+					for scanner.Scan() {
+						if re.Match(scanner.Bytes()) {
+							select {
+							case matched <- struct{}{}:
+							default:
+							}
+
+							cancel()
+
+							return
+						}
+					}
+				}()
+			}
+		}
+	}
+
+	wg.Wait()
+
+	select {
+	case <-matched:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,524 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// ErrMachineDeploymentNotFound is returned when the cluster has no MachineDeployment with the requested name.
+var ErrMachineDeploymentNotFound = errors.New("machine deployment not found")
+
+// MachineDeploymentStatus is a summary of the MachineDeployment state.
+type MachineDeploymentStatus struct {
+	Name                   string
+	Phase                  string
+	InfrastructureTemplate string
+	BootstrapTemplate      string
+	Replicas               int64
+	ReadyReplicas          int64
+	UpdatedReplicas        int64
+	AvailableReplicas      int64
+}
+
+// MachineDeployment returns status summary for the cluster MachineDeployment.
+func (cluster *Cluster) MachineDeployment(ctx context.Context, name string) (*MachineDeploymentStatus, error) {
+	machineDeployment, err := cluster.machineDeployment(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &MachineDeploymentStatus{
+		Name:              machineDeployment.GetName(),
+		ReadyReplicas:     getReplicas(machineDeployment, "readyReplicas"),
+		UpdatedReplicas:   getReplicas(machineDeployment, "updatedReplicas"),
+		AvailableReplicas: getReplicas(machineDeployment, "availableReplicas"),
+	}
+
+	if res.Replicas, _, err = unstructured.NestedInt64(machineDeployment.Object, "spec", "replicas"); err != nil {
+		return nil, err
+	}
+
+	if res.Phase, _, err = unstructured.NestedString(machineDeployment.Object, "status", "phase"); err != nil {
+		return nil, err
+	}
+
+	infrastructureRef, err := getRef(machineDeployment.Object, "spec", "template", "spec", "infrastructureRef")
+	if err != nil {
+		return nil, err
+	}
+
+	res.InfrastructureTemplate = infrastructureRef.Name
+
+	bootstrapRef, err := getRef(machineDeployment.Object, "spec", "template", "spec", "bootstrap", "configRef")
+	if err != nil {
+		return nil, err
+	}
+
+	res.BootstrapTemplate = bootstrapRef.Name
+
+	return res, nil
+}
+
+func (cluster *Cluster) machineDeployment(ctx context.Context, name string) (*unstructured.Unstructured, error) {
+	machineDeployments, err := cluster.Workers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range machineDeployments.Items {
+		if d.GetName() == name {
+			return &machineDeployments.Items[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrMachineDeploymentNotFound, name)
+}
+
+// WaitForMachineDeploymentRollout waits until all MachineDeployment replicas are updated and available.
+//
+// Timeout error reports the last observed rollout progress.
+func (cluster *Cluster) WaitForMachineDeploymentRollout(ctx context.Context, name string) error {
+	// let the controller observe the spec change before checking replicas
+	time.Sleep(2 * time.Second)
+
+	return retry.Constant(60*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		machineDeployment, err := cluster.machineDeployment(ctx, name)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		replicas, _, err := unstructured.NestedInt64(machineDeployment.Object, "spec", "replicas")
+		if err != nil {
+			return err
+		}
+
+		updated := getReplicas(machineDeployment, "updatedReplicas")
+		unavailable := getReplicas(machineDeployment, "unavailableReplicas")
+
+		if updated != replicas || unavailable != 0 {
+			return retry.ExpectedError(fmt.Errorf("machine deployment %s rollout is in progress: %d of %d replicas updated, %d unavailable", name, updated, replicas, unavailable))
+		}
+
+		return checkReplicasReady(*machineDeployment)
+	})
+}
+
+// MachineDeploymentSpec describes a new cluster worker pool.
+type MachineDeploymentSpec struct {
+	// Name of the MachineDeployment, infrastructure and bootstrap templates get the same name.
+	Name string
+	// Replicas is the number of the pool machines.
+	Replicas int64
+	// Source is the MachineDeployment to copy the templates and settings from, defaults to the first cluster MachineDeployment.
+	Source string
+	// InfrastructureOverrides are merged into the copied infrastructure template spec.template.spec, e.g. to set the instance type.
+	InfrastructureOverrides map[string]interface{}
+	// Labels are added to the pool machines.
+	Labels map[string]string
+	// NodeLabels are registered by kubelet on the pool nodes.
+	NodeLabels map[string]string
+	// NodeTaints are registered by kubelet on the pool nodes.
+	NodeTaints []corev1.Taint
+}
+
+// AddMachineDeployment adds a worker pool to the cluster and waits for it to be ready.
+//
+// Infrastructure and bootstrap templates of the source MachineDeployment are copied, so the new pool
+// uses the same provider settings as the existing one unless overridden.
+// Copied templates are deleted if the MachineDeployment can't be created.
+//
+//nolint:gocognit,gocyclo,cyclop
+func (cluster *Cluster) AddMachineDeployment(ctx context.Context, spec MachineDeploymentSpec) (err error) {
+	if spec.Name == "" {
+		return fmt.Errorf("machine deployment name is required")
+	}
+
+	overrides, err := normalizeOverrides(spec.InfrastructureOverrides)
+	if err != nil {
+		return err
+	}
+
+	if err = validateNodeConfig(spec.NodeLabels, spec.NodeTaints); err != nil {
+		return err
+	}
+
+	if err = cluster.sync(ctx); err != nil {
+		return err
+	}
+
+	machineDeployments, err := cluster.Workers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var source *unstructured.Unstructured
+
+	for i := range machineDeployments.Items {
+		machineDeployment := &machineDeployments.Items[i]
+
+		if machineDeployment.GetName() == spec.Name {
+			return fmt.Errorf("machine deployment %s already exists", spec.Name)
+		}
+
+		if source == nil && (spec.Source == "" || machineDeployment.GetName() == spec.Source) {
+			source = machineDeployment
+		}
+	}
+
+	if source == nil {
+		if spec.Source != "" {
+			return fmt.Errorf("%w: %s", ErrMachineDeploymentNotFound, spec.Source)
+		}
+
+		return fmt.Errorf("cluster has no machine deployments to copy templates from")
+	}
+
+	var created []*unstructured.Unstructured
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, template := range created {
+			cluster.manager.runtimeClient.Delete(ctx, template) //nolint:errcheck
+		}
+	}()
+
+	infrastructureTemplate, err := cluster.copyTemplate(ctx, source, spec.Name, func(template *unstructured.Unstructured) error {
+		for k, v := range overrides {
+			if e := unstructured.SetNestedField(template.Object, v, "spec", "template", "spec", k); e != nil {
+				return e
+			}
+		}
+
+		return nil
+	}, "spec", "template", "spec", "infrastructureRef")
+	if err != nil {
+		return err
+	}
+
+	created = append(created, infrastructureTemplate)
+
+	bootstrapTemplate, err := cluster.copyTemplate(ctx, source, spec.Name, func(template *unstructured.Unstructured) error {
+		return setNodeConfig(template, spec.NodeLabels, spec.NodeTaints)
+	}, "spec", "template", "spec", "bootstrap", "configRef")
+	if err != nil {
+		return err
+	}
+
+	created = append(created, bootstrapTemplate)
+
+	machineDeployment := &unstructured.Unstructured{}
+	machineDeployment.SetGroupVersionKind(source.GroupVersionKind())
+	machineDeployment.SetName(spec.Name)
+	machineDeployment.SetNamespace(cluster.namespace)
+	machineDeployment.SetLabels(map[string]string{clusterv1.ClusterLabelName: cluster.name})
+
+	machineDeploymentSpec, _, err := unstructured.NestedMap(source.Object, "spec")
+	if err != nil {
+		return err
+	}
+
+	machineDeployment.Object["spec"] = machineDeploymentSpec
+
+	machineLabels := map[string]interface{}{
+		clusterv1.ClusterLabelName:           cluster.name,
+		clusterv1.MachineDeploymentLabelName: spec.Name,
+	}
+
+	for k, v := range spec.Labels {
+		machineLabels[k] = v
+	}
+
+	for _, field := range []struct {
+		value interface{}
+		path  []string
+	}{
+		{spec.Replicas, []string{"spec", "replicas"}},
+		{map[string]interface{}{clusterv1.MachineDeploymentLabelName: spec.Name}, []string{"spec", "selector", "matchLabels"}},
+		{machineLabels, []string{"spec", "template", "metadata", "labels"}},
+		{infrastructureTemplate.GetName(), []string{"spec", "template", "spec", "infrastructureRef", "name"}},
+		{bootstrapTemplate.GetName(), []string{"spec", "template", "spec", "bootstrap", "configRef", "name"}},
+	} {
+		if err = unstructured.SetNestedField(machineDeployment.Object, field.value, field.path...); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("adding machine deployment %s with %d replicas\n", spec.Name, spec.Replicas)
+
+	if err = cluster.manager.runtimeClient.Create(ctx, machineDeployment); err != nil {
+		return err
+	}
+
+	// templates are used by the MachineDeployment from now on
+	created = nil
+
+	return cluster.WaitForMachineDeploymentRollout(ctx, spec.Name)
+}
+
+// normalizeOverrides converts the overrides to JSON values, so that any Go values (e.g. ints or typed maps) can be used.
+func normalizeOverrides(overrides map[string]interface{}) (map[string]interface{}, error) {
+	if len(overrides) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid infrastructure overrides %w", err)
+	}
+
+	var res map[string]interface{}
+
+	if err = utiljson.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("invalid infrastructure overrides %w", err)
+	}
+
+	return res, nil
+}
+
+// copyTemplate creates a copy of the template referenced by the MachineDeployment with the new name.
+func (cluster *Cluster) copyTemplate(ctx context.Context,
+	machineDeployment *unstructured.Unstructured, name string, mutate func(*unstructured.Unstructured) error, keys ...string,
+) (*unstructured.Unstructured, error) {
+	r, err := getRef(machineDeployment.Object, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	var template unstructured.Unstructured
+
+	template.SetGroupVersionKind(r.gvk)
+
+	if err = cluster.manager.runtimeClient.Get(ctx, r.NamespacedName, &template); err != nil {
+		return nil, err
+	}
+
+	spec, _, err := unstructured.NestedMap(template.Object, "spec")
+	if err != nil {
+		return nil, err
+	}
+
+	res := &unstructured.Unstructured{}
+	res.SetGroupVersionKind(r.gvk)
+	res.SetName(name)
+	res.SetNamespace(r.Namespace)
+	res.SetLabels(map[string]string{clusterv1.ClusterLabelName: cluster.name})
+	res.Object["spec"] = spec
+
+	if err = mutate(res); err != nil {
+		return nil, err
+	}
+
+	if err = cluster.manager.runtimeClient.Create(ctx, res); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, fmt.Errorf("%s %s already exists", r.gvk.Kind, name)
+		}
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// RemoveMachineDeploymentOptions defines additional optional parameters for RemoveMachineDeployment.
+type RemoveMachineDeploymentOptions struct {
+	AllowLastPool bool
+}
+
+// RemoveMachineDeploymentOption optional RemoveMachineDeployment parameter setter.
+type RemoveMachineDeploymentOption func(*RemoveMachineDeploymentOptions)
+
+// AllowLastPool allows removing the last MachineDeployment even if control plane nodes don't schedule workloads.
+func AllowLastPool() RemoveMachineDeploymentOption {
+	return func(opts *RemoveMachineDeploymentOptions) {
+		opts.AllowLastPool = true
+	}
+}
+
+// RemoveMachineDeployment scales the worker pool down to zero, waits for its machines to be deleted,
+// and then removes the MachineDeployment with the templates not used by other MachineDeployments.
+func (cluster *Cluster) RemoveMachineDeployment(ctx context.Context, name string, setters ...RemoveMachineDeploymentOption) error {
+	var opts RemoveMachineDeploymentOptions
+
+	for _, s := range setters {
+		s(&opts)
+	}
+
+	machineDeployments, err := cluster.Workers(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		target     *unstructured.Unstructured
+		others     []unstructured.Unstructured
+		references = map[ref]struct{}{}
+	)
+
+	for i := range machineDeployments.Items {
+		machineDeployment := &machineDeployments.Items[i]
+
+		if machineDeployment.GetName() == name {
+			target = machineDeployment
+
+			continue
+		}
+
+		others = append(others, *machineDeployment)
+	}
+
+	if target == nil {
+		return fmt.Errorf("%w: %s", ErrMachineDeploymentNotFound, name)
+	}
+
+	if len(others) == 0 && !opts.AllowLastPool {
+		var schedulable bool
+
+		if schedulable, err = cluster.controlPlaneSchedulable(ctx); err != nil {
+			return err
+		}
+
+		if !schedulable {
+			return fmt.Errorf("machine deployment %s is the last worker pool and control plane nodes don't schedule workloads, use AllowLastPool to remove it anyway", name)
+		}
+	}
+
+	for i := range others {
+		for _, keys := range machineDeploymentTemplateRefs {
+			var r *ref
+
+			if r, err = getRef(others[i].Object, keys...); err != nil {
+				return err
+			}
+
+			references[*r] = struct{}{}
+		}
+	}
+
+	templates := make([]*ref, 0, len(machineDeploymentTemplateRefs))
+
+	for _, keys := range machineDeploymentTemplateRefs {
+		var r *ref
+
+		if r, err = getRef(target.Object, keys...); err != nil {
+			return err
+		}
+
+		templates = append(templates, r)
+	}
+
+	fmt.Printf("scaling down machine deployment %s\n", name)
+
+	if err = unstructured.SetNestedField(target.Object, int64(0), "spec", "replicas"); err != nil {
+		return err
+	}
+
+	if err = cluster.manager.runtimeClient.Update(ctx, target); err != nil {
+		return err
+	}
+
+	if err = cluster.waitMachineDeploymentMachinesDeleted(ctx, name); err != nil {
+		return err
+	}
+
+	fmt.Printf("removing machine deployment %s\n", name)
+
+	if err = cluster.manager.runtimeClient.Delete(ctx, target); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	for _, r := range templates {
+		if _, ok := references[*r]; ok {
+			continue
+		}
+
+		template := &unstructured.Unstructured{}
+		template.SetGroupVersionKind(r.gvk)
+		template.SetName(r.Name)
+		template.SetNamespace(r.Namespace)
+
+		if err = cluster.manager.runtimeClient.Delete(ctx, template); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var machineDeploymentTemplateRefs = [][]string{
+	{"spec", "template", "spec", "infrastructureRef"},
+	{"spec", "template", "spec", "bootstrap", "configRef"},
+}
+
+// waitMachineDeploymentMachinesDeleted waits until all MachineDeployment machines are gone.
+func (cluster *Cluster) waitMachineDeploymentMachinesDeleted(ctx context.Context, name string) error {
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		machines, err := cluster.machines(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		count := 0
+
+		for _, machine := range machines.Items {
+			if machine.GetLabels()[clusterv1.MachineDeploymentLabelName] == name {
+				count++
+			}
+		}
+
+		if count > 0 {
+			return retry.ExpectedError(fmt.Errorf("machine deployment %s has %d machines left", name, count))
+		}
+
+		return nil
+	})
+}
+
+// controlPlaneSchedulable checks if any workload cluster control plane node accepts workloads.
+func (cluster *Cluster) controlPlaneSchedulable(ctx context.Context) (bool, error) {
+	clientset, err := cluster.workloadClientset(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: "node-role.kubernetes.io/control-plane"})
+	if err != nil {
+		return false, err
+	}
+
+	for _, node := range nodes.Items {
+		tainted := false
+
+		for _, taint := range node.Spec.Taints {
+			if taint.Effect == corev1.TaintEffectNoSchedule &&
+				(taint.Key == "node-role.kubernetes.io/control-plane" || taint.Key == "node-role.kubernetes.io/master") {
+				tainted = true
+
+				break
+			}
+		}
+
+		if !tainted && !node.Spec.Unschedulable {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrMachinePoolsNotSupported is returned when MachinePool CRD is not installed,
+// usually because MachinePool feature gate is disabled.
+var ErrMachinePoolsNotSupported = errors.New("machine pools are not supported by the management cluster, check that MachinePool feature gate is enabled")
+
+// MachinePool is a summary of the MachinePool state.
+type MachinePool struct {
+	Name          string
+	Phase         string
+	Replicas      int64
+	ReadyReplicas int64
+}
+
+// MachinePools lists cluster MachinePools.
+func (cluster *Cluster) MachinePools(ctx context.Context) ([]MachinePool, error) {
+	var machinePools unstructured.UnstructuredList
+
+	machinePools.SetGroupVersionKind(cluster.machinePoolGVK())
+
+	if err := cluster.manager.runtimeClient.List(ctx, &machinePools,
+		runtimeclient.InNamespace(cluster.namespace),
+		runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: cluster.name},
+	); err != nil {
+		if meta.IsNoMatchError(err) {
+			return nil, ErrMachinePoolsNotSupported
+		}
+
+		return nil, err
+	}
+
+	res := make([]MachinePool, 0, len(machinePools.Items))
+
+	for i := range machinePools.Items {
+		machinePool := &machinePools.Items[i]
+
+		pool := MachinePool{
+			Name:          machinePool.GetName(),
+			ReadyReplicas: getReplicas(machinePool, "readyReplicas"),
+		}
+
+		var err error
+
+		if pool.Replicas, _, err = unstructured.NestedInt64(machinePool.Object, "spec", "replicas"); err != nil {
+			return nil, err
+		}
+
+		if pool.Phase, _, err = unstructured.NestedString(machinePool.Object, "status", "phase"); err != nil {
+			return nil, err
+		}
+
+		res = append(res, pool)
+	}
+
+	return res, nil
+}
+
+// ScaleMachinePool sets MachinePool replicas and waits until all replicas are ready.
+func (cluster *Cluster) ScaleMachinePool(ctx context.Context, name string, replicas int32) error {
+	var machinePool unstructured.Unstructured
+
+	machinePool.SetGroupVersionKind(cluster.machinePoolGVK())
+
+	key := types.NamespacedName{Name: name, Namespace: cluster.namespace}
+
+	if err := cluster.manager.runtimeClient.Get(ctx, key, &machinePool); err != nil {
+		if meta.IsNoMatchError(err) {
+			return ErrMachinePoolsNotSupported
+		}
+
+		return err
+	}
+
+	if err := unstructured.SetNestedField(machinePool.Object, int64(replicas), "spec", "replicas"); err != nil {
+		return err
+	}
+
+	if err := cluster.manager.runtimeClient.Update(ctx, &machinePool); err != nil {
+		return err
+	}
+
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		if err := cluster.manager.runtimeClient.Get(ctx, key, &machinePool); err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		if c := getReplicas(&machinePool, "readyReplicas"); c != int64(replicas) {
+			return retry.ExpectedError(fmt.Errorf("expected %d, current ready replicas count: %d", replicas, c))
+		}
+
+		return nil
+	})
+}
+
+func (cluster *Cluster) machinePoolGVK() schema.GroupVersionKind {
+	return schema.GroupVersionKind{
+		Version: cluster.manager.version,
+		Group:   "cluster.x-k8s.io",
+		Kind:    "MachinePool",
+	}
+}
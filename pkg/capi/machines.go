@@ -0,0 +1,251 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WaitForMachines waits until at least count cluster Machines reach the phase, e.g. Running.
+//
+// Timeout error lists the Machines which are not in the phase together with their last failure message.
+func (cluster *Cluster) WaitForMachines(ctx context.Context, phase string, count int) error {
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		machines, err := cluster.machines(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		var (
+			matched int
+			stuck   []string
+		)
+
+		for i := range machines.Items {
+			var machinePhase, message string
+
+			machine := &machines.Items[i]
+
+			if machinePhase, _, err = unstructured.NestedString(machine.Object, "status", "phase"); err != nil {
+				return err
+			}
+
+			if machinePhase == phase {
+				matched++
+
+				continue
+			}
+
+			if message, err = machineFailureMessage(machine); err != nil {
+				return err
+			}
+
+			stuck = append(stuck, fmt.Sprintf("%s (%s): %s", machine.GetName(), machinePhase, message))
+		}
+
+		if matched < count {
+			return retry.ExpectedError(fmt.Errorf("%d of %d machines are %s, pending machines: %s", matched, count, phase, strings.Join(stuck, "; ")))
+		}
+
+		return nil
+	})
+}
+
+// RemediateMachine deletes the Machine, so that its owner (MachineSet or control plane) recreates it,
+// and waits for the replacement Machine to be Running.
+//
+// The last control plane Machine is never remediated, as it would break etcd quorum.
+func (cluster *Cluster) RemediateMachine(ctx context.Context, name string) error {
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		target        *unstructured.Unstructured
+		controlPlanes int
+	)
+
+	existing := map[string]struct{}{}
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+
+		existing[machine.GetName()] = struct{}{}
+
+		if _, ok := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]; ok {
+			controlPlanes++
+		}
+
+		if machine.GetName() == name {
+			target = machine
+		}
+	}
+
+	if target == nil {
+		return fmt.Errorf("machine %s not found in cluster %s", name, cluster.name)
+	}
+
+	owner := metav1.GetControllerOf(target)
+	if owner == nil {
+		return fmt.Errorf("machine %s has no controller owner, it won't be recreated", name)
+	}
+
+	if _, ok := target.GetLabels()[clusterv1.MachineControlPlaneLabelName]; ok && controlPlanes <= 1 {
+		return fmt.Errorf("refusing to remediate the last control plane machine %s", name)
+	}
+
+	fmt.Printf("deleting machine %s\n", name)
+
+	if err = cluster.manager.runtimeClient.Delete(ctx, target); err != nil {
+		return err
+	}
+
+	return retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		current, err := cluster.machines(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		for i := range current.Items {
+			machine := &current.Items[i]
+
+			if _, ok := existing[machine.GetName()]; ok {
+				continue
+			}
+
+			if o := metav1.GetControllerOf(machine); o == nil || o.UID != owner.UID {
+				continue
+			}
+
+			phase, _, err := unstructured.NestedString(machine.Object, "status", "phase")
+			if err != nil {
+				return err
+			}
+
+			if clusterv1.MachinePhase(phase) != clusterv1.MachinePhaseRunning {
+				return retry.ExpectedError(fmt.Errorf("replacement machine %s is %s", machine.GetName(), phase))
+			}
+
+			return nil
+		}
+
+		return retry.ExpectedError(fmt.Errorf("waiting for machine %s replacement to be created", name))
+	})
+}
+
+// ValidateBootstrapData returns names of the cluster Machines which have no populated bootstrap data secret.
+//
+// Machine is reported if the bootstrap data secret is not set, doesn't exist or has an empty value.
+// Machines being deleted are skipped.
+func (cluster *Cluster) ValidateBootstrapData(ctx context.Context) ([]string, error) {
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := []string{}
+
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+
+		if machine.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		var secretName string
+
+		if secretName, _, err = unstructured.NestedString(machine.Object, "spec", "bootstrap", "dataSecretName"); err != nil {
+			return nil, err
+		}
+
+		if secretName == "" {
+			invalid = append(invalid, machine.GetName())
+
+			continue
+		}
+
+		var secret *corev1.Secret
+
+		secret, err = cluster.manager.clientset.CoreV1().Secrets(cluster.namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				invalid = append(invalid, machine.GetName())
+
+				continue
+			}
+
+			return nil, err
+		}
+
+		if len(secret.Data["value"]) == 0 {
+			invalid = append(invalid, machine.GetName())
+		}
+	}
+
+	sort.Strings(invalid)
+
+	return invalid, nil
+}
+
+// machines lists all cluster Machines.
+func (cluster *Cluster) machines(ctx context.Context) (*unstructured.UnstructuredList, error) {
+	var machines unstructured.UnstructuredList
+
+	machines.SetGroupVersionKind(
+		schema.GroupVersionKind{
+			Version: cluster.manager.version,
+			Group:   "cluster.x-k8s.io",
+			Kind:    "Machine",
+		},
+	)
+
+	if err := cluster.manager.runtimeClient.List(ctx, &machines,
+		runtimeclient.InNamespace(cluster.namespace),
+		runtimeclient.MatchingLabels{clusterv1.ClusterLabelName: cluster.name},
+	); err != nil {
+		return nil, err
+	}
+
+	return &machines, nil
+}
+
+// machineFailureMessage returns Machine failure message or the message of the Ready condition.
+func machineFailureMessage(machine *unstructured.Unstructured) (string, error) {
+	message, _, err := unstructured.NestedString(machine.Object, "status", "failureMessage")
+	if err != nil {
+		return "", err
+	}
+
+	if message != "" {
+		return message, nil
+	}
+
+	conditions, err := getConditions(machine.Object)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cond := range conditions {
+		if cond.Type == clusterv1.ReadyCondition {
+			return strings.TrimSpace(cond.Reason + " " + cond.Message), nil
+		}
+	}
+
+	return "", nil
+}
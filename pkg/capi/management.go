@@ -0,0 +1,66 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/version"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+// managementKubernetesVersions is the range of management cluster Kubernetes minor versions supported by the CAPI contract.
+var managementKubernetesVersions = map[string]struct{ min, max string }{
+	"v1alpha3": {min: "1.16", max: "1.22"},
+	"v1alpha4": {min: "1.19", max: "1.23"},
+	"v1beta1":  {min: "1.20", max: "1.23"},
+}
+
+// ManagementKubernetesVersion returns the Kubernetes version of the management cluster.
+func (clusterAPI *Manager) ManagementKubernetesVersion() (string, error) {
+	info, err := clusterAPI.clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+
+	return info.GitVersion, nil
+}
+
+// checkManagementKubernetesVersion warns if the management cluster Kubernetes version is not supported
+// by the contract of the core provider being installed.
+func (clusterAPI *Manager) checkManagementKubernetesVersion(ctx context.Context) error {
+	current, err := clusterAPI.ManagementKubernetesVersion()
+	if err != nil {
+		return err
+	}
+
+	metadata, err := clusterAPI.ProviderMetadata(ctx, constants.CoreProviderName)
+	if err != nil {
+		fmt.Printf("warning: skipping management cluster version check: %s\n", err)
+
+		return nil
+	}
+
+	supported, ok := managementKubernetesVersions[metadata.Contract]
+	if !ok {
+		return nil
+	}
+
+	v, err := version.ParseGeneric(current)
+	if err != nil {
+		return err
+	}
+
+	minor := version.MustParseGeneric(fmt.Sprintf("%d.%d", v.Major(), v.Minor()))
+
+	if minor.LessThan(version.MustParseGeneric(supported.min)) || version.MustParseGeneric(supported.max).LessThan(minor) {
+		fmt.Printf("warning: management cluster Kubernetes %s is not supported by %s %s (contract %s), supported versions are %s-%s\n",
+			current, metadata.Name, metadata.Version, metadata.Contract, supported.min, supported.max)
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"encoding/json"
+	"runtime/debug"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+const (
+	managedByLabel          = "app.kubernetes.io/managed-by"
+	managedByAnnotation     = "capi-utils.talos-systems.com/managed-by"
+	versionAnnotation       = "capi-utils.talos-systems.com/version"
+	lastAppliedAtAnnotation = "capi-utils.talos-systems.com/last-applied-at"
+	managedByValue          = "capi-utils"
+	capiUtilsModule         = "github.com/talos-systems/capi-utils"
+	unknownCAPIUtilsVersion = "unknown"
+)
+
+// ManagementMetadata describes which tool installed cluster API components last.
+//
+// Empty ManagedBy means that the components were installed by some other tool, e.g. raw clusterctl.
+type ManagementMetadata struct {
+	ManagedBy     string
+	Version       string
+	LastAppliedAt time.Time
+}
+
+// ManagementMetadata reads capi-utils metadata from the core CAPI namespace.
+func (clusterAPI *Manager) ManagementMetadata(ctx context.Context) (*ManagementMetadata, error) {
+	ns, err := clusterAPI.clientset.CoreV1().Namespaces().Get(ctx, constants.CoreCAPINamespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := ns.GetAnnotations()
+
+	res := &ManagementMetadata{
+		ManagedBy: annotations[managedByAnnotation],
+		Version:   annotations[versionAnnotation],
+	}
+
+	if v, ok := annotations[lastAppliedAtAnnotation]; ok {
+		if res.LastAppliedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
+
+// writeManagementMetadata marks the core CAPI namespace as managed by capi-utils.
+func (clusterAPI *Manager) writeManagementMetadata(ctx context.Context) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				managedByLabel: managedByValue,
+			},
+			"annotations": map[string]string{
+				managedByAnnotation:     managedByValue,
+				versionAnnotation:       capiUtilsVersion(),
+				lastAppliedAtAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = clusterAPI.clientset.CoreV1().Namespaces().Patch(ctx, constants.CoreCAPINamespace, types.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+func capiUtilsVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return unknownCAPIUtilsVersion
+	}
+
+	if info.Main.Path == capiUtilsModule {
+		return info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == capiUtilsModule {
+			return dep.Version
+		}
+	}
+
+	return unknownCAPIUtilsVersion
+}
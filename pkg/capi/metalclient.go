@@ -5,12 +5,49 @@
 package capi
 
 import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// FieldIndex is a field index registered in the cached client.
+type FieldIndex struct {
+	GVK     schema.GroupVersionKind
+	Field   string
+	Extract runtimeclient.IndexerFunc
+}
+
+// MachineClusterNameIndex indexes Machines by spec.clusterName.
+//
+// Use runtimeclient.MatchingFields{"spec.clusterName": name} to list cluster Machines with the cached client.
+func MachineClusterNameIndex(version string) FieldIndex {
+	return FieldIndex{
+		GVK: schema.GroupVersionKind{
+			Group:   "cluster.x-k8s.io",
+			Kind:    "Machine",
+			Version: version,
+		},
+		Field: "spec.clusterName",
+		Extract: func(obj runtimeclient.Object) []string {
+			u, ok := obj.(*unstructured.Unstructured)
+			if !ok {
+				return nil
+			}
+
+			clusterName, _, _ := unstructured.NestedString(u.Object, "spec", "clusterName")
+
+			return []string{clusterName}
+		},
+	}
+}
+
 // GetMetalClient builds k8s client with schemes required to access all the CAPI/Sidero/Talos components.
 func GetMetalClient(config *rest.Config) (runtimeclient.Client, error) {
 	scheme := runtime.NewScheme()
@@ -21,3 +58,46 @@ func GetMetalClient(config *rest.Config) (runtimeclient.Client, error) {
 
 	return runtimeclient.New(config, runtimeclient.Options{Scheme: scheme})
 }
+
+// GetCachedMetalClient builds k8s client which reads objects from the informer cache with the field indexes.
+//
+// Filtered lists by the indexed fields are served from memory, which suits heavy list workloads, e.g. dashboards.
+// Cache is eventually consistent and it is stopped once the context is canceled.
+func GetCachedMetalClient(ctx context.Context, config *rest.Config, indexes ...FieldIndex) (runtimeclient.Client, error) {
+	scheme := runtime.NewScheme()
+
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+
+	informers, err := cache.New(config, cache.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(index.GVK)
+
+		if err = informers.IndexField(ctx, obj, index.Field, index.Extract); err != nil {
+			return nil, fmt.Errorf("failed to index %s %s %w", index.GVK.Kind, index.Field, err)
+		}
+	}
+
+	go informers.Start(ctx) //nolint:errcheck
+
+	if !informers.WaitForCacheSync(ctx) {
+		return nil, fmt.Errorf("failed to sync cache")
+	}
+
+	direct, err := runtimeclient.New(config, runtimeclient.Options{Scheme: scheme})
+	if err != nil {
+		return nil, err
+	}
+
+	return runtimeclient.NewDelegatingClient(runtimeclient.NewDelegatingClientInput{
+		CacheReader:       informers,
+		Client:            direct,
+		CacheUnstructured: true,
+	})
+}
@@ -0,0 +1,188 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+)
+
+const (
+	// metricsCacheTTL is how long collected values are served before the API is queried again.
+	metricsCacheTTL = 30 * time.Second
+
+	// metricsCollectTimeout bounds the API calls done on scrape.
+	metricsCollectTimeout = 20 * time.Second
+)
+
+var (
+	clustersDesc = prometheus.NewDesc(
+		"capi_clusters",
+		"Number of clusters by phase.",
+		[]string{"phase"}, nil,
+	)
+	machinesDesc = prometheus.NewDesc(
+		"capi_machines",
+		"Number of machines by phase.",
+		[]string{"phase"}, nil,
+	)
+	providerReadyDesc = prometheus.NewDesc(
+		"capi_provider_ready",
+		"Whether all controller deployments of the provider are available.",
+		[]string{"provider", "namespace", "version"}, nil,
+	)
+)
+
+// collector is the Prometheus collector of the management cluster state.
+type collector struct {
+	manager *Manager
+
+	mu        sync.Mutex
+	collected time.Time
+	metrics   []prometheus.Metric
+}
+
+// Collector returns Prometheus collector exposing the number of clusters and machines by phase and provider readiness.
+//
+// Values are fetched from the API on scrape and cached for metricsCacheTTL, so frequent scrapes don't load the API.
+func (clusterAPI *Manager) Collector() prometheus.Collector {
+	return &collector{
+		manager: clusterAPI,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- machinesDesc
+	ch <- providerReadyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.collected) > metricsCacheTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsCollectTimeout)
+		defer cancel()
+
+		metrics, err := c.collect(ctx)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(clustersDesc, err)
+
+			return
+		}
+
+		c.metrics = metrics
+		c.collected = time.Now()
+	}
+
+	for _, metric := range c.metrics {
+		ch <- metric
+	}
+}
+
+func (c *collector) collect(ctx context.Context) ([]prometheus.Metric, error) {
+	metrics := []prometheus.Metric{}
+
+	for _, kind := range []struct {
+		desc *prometheus.Desc
+		kind string
+	}{
+		{clustersDesc, "Cluster"},
+		{machinesDesc, "Machine"},
+	} {
+		phases, err := c.countPhases(ctx, kind.kind)
+		if err != nil {
+			return nil, err
+		}
+
+		for phase, count := range phases {
+			metrics = append(metrics, prometheus.MustNewConstMetric(kind.desc, prometheus.GaugeValue, float64(count), phase))
+		}
+	}
+
+	providers, err := c.manager.listProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, provider := range providers {
+		label := clusterctlv1.ManifestLabel(provider.ProviderName, provider.GetProviderType())
+
+		var value float64
+
+		if c.providerReady(ctx, label) {
+			value = 1
+		}
+
+		metrics = append(metrics, prometheus.MustNewConstMetric(providerReadyDesc, prometheus.GaugeValue, value, label, provider.Namespace, provider.Version))
+	}
+
+	return metrics, nil
+}
+
+// countPhases counts objects of the kind by status.phase, objects without phase are counted as Pending.
+func (c *collector) countPhases(ctx context.Context, kind string) (map[string]int, error) {
+	var list unstructured.UnstructuredList
+
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    kind,
+		Version: c.manager.version,
+	})
+
+	if err := c.manager.runtimeClient.List(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	phases := map[string]int{}
+
+	for _, obj := range list.Items {
+		phase, _, err := unstructured.NestedString(obj.Object, "status", "phase")
+		if err != nil {
+			return nil, err
+		}
+
+		if phase == "" {
+			phase = "Pending"
+		}
+
+		phases[phase]++
+	}
+
+	return phases, nil
+}
+
+// providerReady returns true if all controller deployments of the provider have all replicas available.
+//
+// Provider without deployments or failing to list them is reported as not ready.
+func (c *collector) providerReady(ctx context.Context, label string) bool {
+	deployments, err := c.manager.providerDeployments(ctx, label)
+	if err != nil || len(deployments) == 0 {
+		return false
+	}
+
+	for _, deployment := range deployments {
+		var replicas int32 = 1
+
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+
+		if deployment.Status.AvailableReplicas < replicas {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,246 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/clientcmd"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MoveCount is the number of objects of a kind in the source before the move and in the target after the move.
+type MoveCount struct {
+	Source int
+	Target int
+}
+
+// MoveResult is the verification result of Move.
+type MoveResult struct {
+	// Counts maps cluster API kinds to the object counts.
+	Counts map[string]MoveCount
+
+	// Missing lists the source objects which were not found in the target, formatted as Kind/name.
+	Missing []string
+}
+
+// Move moves cluster API objects of the namespace from the management cluster to the target management cluster.
+//
+// Target can be either the permanent management cluster (pivot) or a temporary cluster to move back to,
+// it should have the same providers installed. After the move object counts are compared between the source
+// and the target, objects missing in the target are reported in the result and the error.
+func (clusterAPI *Manager) Move(ctx context.Context, target client.Kubeconfig, namespace string, setters ...OperationOption) (*MoveResult, error) {
+	opts := newOperationOptions(setters)
+
+	if clusterAPI.options.Proxy != nil {
+		return nil, fmt.Errorf("move is not supported with a proxy, use kubeconfig to access the management cluster")
+	}
+
+	targetConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: target.Path},
+		&clientcmd.ConfigOverrides{CurrentContext: target.Context},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load target kubeconfig %w", err)
+	}
+
+	targetClient, err := GetMetalClient(targetConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = clusterAPI.checkMoveTargetProviders(ctx, targetClient); err != nil {
+		return nil, err
+	}
+
+	kinds, err := clusterAPI.capiKinds()
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := listMoveObjects(ctx, clusterAPI.runtimeClient, kinds, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = clusterAPI.client.Move(client.MoveOptions{
+		FromKubeconfig: clusterAPI.kubeconfig,
+		ToKubeconfig:   target,
+		Namespace:      namespace,
+		DryRun:         opts.DryRun,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to move namespace %s %w", namespace, err)
+	}
+
+	if opts.DryRun {
+		fmt.Printf("dry run: %d objects would be moved from namespace %s\n", len(source), namespace)
+
+		return nil, nil
+	}
+
+	moved, err := listMoveObjects(ctx, targetClient, kinds, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the move %w", err)
+	}
+
+	result := &MoveResult{
+		Counts: map[string]MoveCount{},
+	}
+
+	for obj, kind := range source {
+		count := result.Counts[kind]
+		count.Source++
+		result.Counts[kind] = count
+
+		if _, ok := moved[obj]; !ok {
+			result.Missing = append(result.Missing, obj)
+		}
+	}
+
+	for _, kind := range moved {
+		count := result.Counts[kind]
+		count.Target++
+		result.Counts[kind] = count
+	}
+
+	if len(result.Missing) > 0 {
+		sort.Strings(result.Missing)
+
+		return result, fmt.Errorf("%d objects failed to move: %s", len(result.Missing), strings.Join(result.Missing, ", "))
+	}
+
+	return result, nil
+}
+
+// checkMoveTargetProviders verifies that all providers of the management cluster are installed in the target.
+func (clusterAPI *Manager) checkMoveTargetProviders(ctx context.Context, targetClient runtimeclient.Client) error {
+	providers, err := clusterAPI.listProviders(ctx)
+	if err != nil {
+		return err
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err = targetClient.List(ctx, list); err != nil {
+		return fmt.Errorf("failed to list target cluster providers %w", err)
+	}
+
+	installed := map[string]struct{}{}
+
+	for _, provider := range list.Items {
+		providerName, _, _ := unstructured.NestedString(provider.Object, "providerName")
+		providerType, _, _ := unstructured.NestedString(provider.Object, "type")
+
+		installed[clusterctlv1.ManifestLabel(providerName, clusterctlv1.ProviderType(providerType))] = struct{}{}
+	}
+
+	var missing []string
+
+	for _, provider := range providers {
+		label := clusterctlv1.ManifestLabel(provider.ProviderName, provider.GetProviderType())
+
+		if _, ok := installed[label]; !ok {
+			missing = append(missing, label)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("target cluster is missing providers: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// listMoveObjects lists cluster API objects in the namespace mapping Kind/name to the kind.
+func listMoveObjects(ctx context.Context, c runtimeclient.Client, kinds []schema.GroupVersionKind, namespace string) (map[string]string, error) {
+	res := map[string]string{}
+
+	for _, gvk := range kinds {
+		var list unstructured.UnstructuredList
+
+		list.SetGroupVersionKind(gvk)
+
+		if err := c.List(ctx, &list, runtimeclient.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("failed to list %s %w", gvk.Kind, err)
+		}
+
+		for _, obj := range list.Items {
+			res[fmt.Sprintf("%s/%s", gvk.Kind, obj.GetName())] = gvk.Kind
+		}
+	}
+
+	return res, nil
+}
+
+// PausedClusters lists clusters in all namespaces which have spec.paused set, e.g. left by an interrupted Move.
+func (clusterAPI *Manager) PausedClusters(ctx context.Context) ([]*Cluster, error) {
+	var list unstructured.UnstructuredList
+
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	if err := clusterAPI.runtimeClient.List(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	res := []*Cluster{}
+
+	for _, obj := range list.Items {
+		paused, _, err := unstructured.NestedBool(obj.Object, "spec", "paused")
+		if err != nil {
+			return nil, err
+		}
+
+		if !paused {
+			continue
+		}
+
+		res = append(res, &Cluster{
+			manager:   clusterAPI,
+			name:      obj.GetName(),
+			namespace: obj.GetNamespace(),
+			cluster:   obj,
+		})
+	}
+
+	return res, nil
+}
+
+// ResumeAll resumes reconciling all paused clusters.
+//
+// Clusters should be resumed only on the management cluster which owns them after the move,
+// resuming the same clusters on both sides makes both management clusters reconcile the same infrastructure.
+func (clusterAPI *Manager) ResumeAll(ctx context.Context, setters ...OperationOption) error {
+	clusters, err := clusterAPI.PausedClusters(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, cluster := range clusters {
+		if err = cluster.Resume(ctx, setters...); err != nil {
+			errs = append(errs, fmt.Errorf("failed to resume cluster %s/%s %w", cluster.namespace, cluster.name, err))
+
+			continue
+		}
+
+		fmt.Printf("resumed cluster %s/%s\n", cluster.namespace, cluster.name)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
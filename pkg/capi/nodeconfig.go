@@ -0,0 +1,194 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	kubeletNodeLabelsArg = "node-labels"
+	kubeletTaintsArg     = "register-with-taints"
+
+	kubeletExtraArgsPath = "/machine/kubelet/extraArgs"
+)
+
+// validateNodeConfig checks node labels and taints syntax.
+func validateNodeConfig(labels map[string]string, taints []corev1.Taint) error {
+	for k, v := range labels {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("invalid node label key %q: %s", k, strings.Join(errs, "; "))
+		}
+
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("invalid node label %q value %q: %s", k, v, strings.Join(errs, "; "))
+		}
+	}
+
+	for _, taint := range taints {
+		if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+			return fmt.Errorf("invalid node taint key %q: %s", taint.Key, strings.Join(errs, "; "))
+		}
+
+		if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+			return fmt.Errorf("invalid node taint %q value %q: %s", taint.Key, taint.Value, strings.Join(errs, "; "))
+		}
+
+		switch taint.Effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid node taint %q effect %q", taint.Key, taint.Effect)
+		}
+	}
+
+	return nil
+}
+
+// setNodeConfig injects kubelet node labels and taints into the bootstrap config template.
+func setNodeConfig(template *unstructured.Unstructured, labels map[string]string, taints []corev1.Taint) error {
+	if len(labels) == 0 && len(taints) == 0 {
+		return nil
+	}
+
+	args := map[string]interface{}{}
+
+	if len(labels) > 0 {
+		args[kubeletNodeLabelsArg] = formatNodeLabels(labels)
+	}
+
+	if len(taints) > 0 {
+		args[kubeletTaintsArg] = formatNodeTaints(taints)
+	}
+
+	switch template.GetKind() {
+	case "TalosConfigTemplate":
+		patches, _, err := unstructured.NestedSlice(template.Object, "spec", "template", "spec", "configPatches")
+		if err != nil {
+			return err
+		}
+
+		// JSON patch can't add a key into the missing object, generated Talos config has no kubelet extraArgs,
+		// so the object is created unless previous patches already do it, and then the args are added one by one
+		if !hasConfigPatch(patches, kubeletExtraArgsPath) {
+			patches = append(patches, map[string]interface{}{
+				"op":    "add",
+				"path":  kubeletExtraArgsPath,
+				"value": map[string]interface{}{},
+			})
+		}
+
+		keys := make([]string, 0, len(args))
+
+		for key := range args {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			patches = append(patches, map[string]interface{}{
+				"op":    "add",
+				"path":  kubeletExtraArgsPath + "/" + escapeJSONPointer(key),
+				"value": args[key],
+			})
+		}
+
+		return unstructured.SetNestedSlice(template.Object, patches, "spec", "template", "spec", "configPatches")
+	case "KubeadmConfigTemplate":
+		path := []string{"spec", "template", "spec", "joinConfiguration", "nodeRegistration"}
+
+		kubeletArgs, _, err := unstructured.NestedMap(template.Object, append(path, "kubeletExtraArgs")...)
+		if err != nil {
+			return err
+		}
+
+		if kubeletArgs == nil {
+			kubeletArgs = map[string]interface{}{}
+		}
+
+		if value, ok := args[kubeletNodeLabelsArg]; ok {
+			kubeletArgs[kubeletNodeLabelsArg] = value
+		}
+
+		if err = unstructured.SetNestedMap(template.Object, kubeletArgs, append(path, "kubeletExtraArgs")...); err != nil {
+			return err
+		}
+
+		if len(taints) == 0 {
+			return nil
+		}
+
+		nodeTaints := make([]interface{}, 0, len(taints))
+
+		for _, taint := range taints {
+			nodeTaints = append(nodeTaints, map[string]interface{}{
+				"key":    taint.Key,
+				"value":  taint.Value,
+				"effect": string(taint.Effect),
+			})
+		}
+
+		return unstructured.SetNestedSlice(template.Object, nodeTaints, append(path, "taints")...)
+	default:
+		return fmt.Errorf("node labels and taints are not supported for bootstrap template %s", template.GetKind())
+	}
+}
+
+func formatNodeLabels(labels map[string]string) string {
+	res := make([]string, 0, len(labels))
+
+	for k, v := range labels {
+		res = append(res, k+"="+v)
+	}
+
+	sort.Strings(res)
+
+	return strings.Join(res, ",")
+}
+
+func formatNodeTaints(taints []corev1.Taint) string {
+	res := make([]string, 0, len(taints))
+
+	for _, taint := range taints {
+		res = append(res, fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect))
+	}
+
+	return strings.Join(res, ",")
+}
+
+// hasConfigPatch checks if any of the Talos config patches adds or replaces the path or its parent.
+func hasConfigPatch(patches []interface{}, path string) bool {
+	for _, patch := range patches {
+		p, ok := patch.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		patchPath, _ := p["path"].(string)
+
+		switch p["op"] {
+		case "add", "replace":
+		default:
+			continue
+		}
+
+		if patchPath == path || (patchPath != "" && strings.HasPrefix(path, patchPath+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// escapeJSONPointer escapes the JSON pointer reference token as defined in RFC 6901.
+func escapeJSONPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,111 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// MachineNodeOptions defines additional optional parameters for MachineNodeMap.
+type MachineNodeOptions struct {
+	CheckNodes bool
+}
+
+// MachineNodeOption optional MachineNodeMap parameter setter.
+type MachineNodeOption func(*MachineNodeOptions)
+
+// CheckNodes verifies that referenced Nodes exist in the workload cluster.
+func CheckNodes() MachineNodeOption {
+	return func(opts *MachineNodeOptions) {
+		opts.CheckNodes = true
+	}
+}
+
+// MachineNodeMap maps cluster Machine names to Node names from the Machine status.nodeRef.
+//
+// Machines which haven't joined the cluster yet map to an empty string.
+// With CheckNodes, Machines referencing Nodes missing from the workload cluster map to an empty string as well.
+func (cluster *Cluster) MachineNodeMap(ctx context.Context, setters ...MachineNodeOption) (map[string]string, error) {
+	var opts MachineNodeOptions
+
+	for _, setter := range setters {
+		setter(&opts)
+	}
+
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(machines.Items))
+
+	for _, machine := range machines.Items {
+		var nodeName string
+
+		if nodeName, _, err = unstructured.NestedString(machine.Object, "status", "nodeRef", "name"); err != nil {
+			return nil, err
+		}
+
+		result[machine.GetName()] = nodeName
+	}
+
+	if !opts.CheckNodes {
+		return result, nil
+	}
+
+	nodes, err := cluster.workloadNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for machine, nodeName := range result {
+		if _, ok := nodes[nodeName]; !ok {
+			result[machine] = ""
+		}
+	}
+
+	return result, nil
+}
+
+// workloadNodes returns the set of Node names in the workload cluster.
+func (cluster *Cluster) workloadNodes(ctx context.Context) (map[string]struct{}, error) {
+	clientset, err := cluster.workloadClientset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]struct{}, len(nodes.Items))
+
+	for _, node := range nodes.Items {
+		result[node.Name] = struct{}{}
+	}
+
+	return result, nil
+}
+
+// workloadClientset builds the workload cluster clientset from the cluster kubeconfig.
+func (cluster *Cluster) workloadClientset(ctx context.Context) (*kubernetes.Clientset, error) {
+	raw, err := cluster.GetWorkloadKubeconfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := clientcmd.RESTConfigFromKubeConfig(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(config)
+}
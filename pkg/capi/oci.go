@@ -0,0 +1,412 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+)
+
+const (
+	ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ociTitleAnnotation   = "org.opencontainers.image.title"
+
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+	dockerHubAuthKey  = "https://index.docker.io/v1/"
+)
+
+// RegistryAuth is the registry credentials used to pull OCI provider artifacts.
+type RegistryAuth struct {
+	Username string
+	Password string
+}
+
+type ociManifest struct {
+	Layers []struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      digest.Digest     `json:"digest"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"layers"`
+}
+
+// ociClient pulls artifacts using the OCI distribution API.
+type ociClient struct {
+	client *http.Client
+	auth   *RegistryAuth
+	token  string
+}
+
+// pullOCIProviders downloads OCI provider artifacts into a clusterctl local repository layout.
+//
+// Returns provider labels mapped to the downloaded directories.
+func (clusterAPI *Manager) pullOCIProviders(ctx context.Context) (map[string]string, error) {
+	if len(clusterAPI.options.OCIProviderRepository) == 0 {
+		return nil, nil
+	}
+
+	// clusterctl reads the pulled components lazily, so the directory is kept until Close
+	base, err := clusterAPI.createTempDir(ociTempDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(clusterAPI.options.OCIProviderRepository))
+
+	for label, ref := range clusterAPI.options.OCIProviderRepository {
+		name, providerType, err := parseProviderLabel(label)
+		if err != nil {
+			return nil, err
+		}
+
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse OCI reference for provider %s %w", label, err)
+		}
+
+		version := clusterAPI.requestedVersion(name, providerType)
+		manifestRef := version
+
+		if tagged, ok := named.(reference.Tagged); ok {
+			var matches bool
+
+			if matches, err = infrastructure.MatchVersion(version, tagged.Tag()); err != nil {
+				return nil, fmt.Errorf("provider %s OCI reference tag %s is not a version %w", label, tagged.Tag(), err)
+			}
+
+			if version != "" && !matches {
+				return nil, fmt.Errorf("provider %s OCI reference has tag %s, but %s is requested", label, tagged.Tag(), version)
+			}
+
+			version = tagged.Tag()
+			manifestRef = version
+		}
+
+		if canonical, ok := named.(reference.Canonical); ok {
+			manifestRef = canonical.Digest().String()
+		}
+
+		if version == "" || infrastructure.IsVersionRange(version) {
+			return nil, fmt.Errorf("provider %s version should be set either in the OCI reference tag or in the provider options", label)
+		}
+
+		dir := filepath.Join(base, label, version)
+
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+
+		domain := reference.Domain(named)
+
+		auth := clusterAPI.options.RegistryAuth
+		if auth == nil {
+			if auth, err = dockerConfigAuth(domain); err != nil {
+				return nil, err
+			}
+		}
+
+		oci := &ociClient{
+			client: http.DefaultClient,
+			auth:   auth,
+		}
+
+		if err = oci.pull(ctx, registryHost(domain), reference.Path(named), manifestRef, dir); err != nil {
+			return nil, fmt.Errorf("failed to pull provider %s from %s %w", label, ref, err)
+		}
+
+		res[label] = dir
+	}
+
+	return res, nil
+}
+
+// pull downloads provider metadata and components layers of the artifact into the directory.
+func (oci *ociClient) pull(ctx context.Context, registry, repository, ref, dir string) error {
+	resp, err := oci.get(ctx, fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, ref), ociManifestMediaType)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	var manifest ociManifest
+
+	if err = json.NewDecoder(resp.Body).Decode(&manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest %w", err)
+	}
+
+	for _, file := range []string{localMetadataFile, localComponentsFile} {
+		found := false
+
+		for _, layer := range manifest.Layers {
+			if layer.Annotations[ociTitleAnnotation] != file {
+				continue
+			}
+
+			if err = oci.pullBlob(ctx, registry, repository, layer.Digest, filepath.Join(dir, file)); err != nil {
+				return err
+			}
+
+			found = true
+
+			break
+		}
+
+		if !found {
+			return fmt.Errorf("artifact has no layer with %s title %s", ociTitleAnnotation, file)
+		}
+	}
+
+	return nil
+}
+
+func (oci *ociClient) pullBlob(ctx context.Context, registry, repository string, dgst digest.Digest, path string) error {
+	if err := dgst.Validate(); err != nil {
+		return err
+	}
+
+	resp, err := oci.get(ctx, fmt.Sprintf("https://%s/v2/%s/blobs/%s", registry, repository, dgst), "")
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	verifier := dgst.Verifier()
+
+	if _, err = io.Copy(io.MultiWriter(f, verifier), resp.Body); err != nil {
+		return err
+	}
+
+	if !verifier.Verified() {
+		return fmt.Errorf("blob %s digest mismatch", dgst)
+	}
+
+	return f.Close()
+}
+
+// get does the registry request, authenticating on the registry challenge if needed.
+func (oci *ociClient) get(ctx context.Context, u, accept string) (*http.Response, error) {
+	resp, err := oci.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && oci.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+
+		resp.Body.Close() //nolint:errcheck
+
+		if err = oci.authenticate(ctx, challenge); err != nil {
+			return nil, err
+		}
+
+		if resp, err = oci.do(ctx, u, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close() //nolint:errcheck
+
+		return nil, fmt.Errorf("registry request %s failed: %s", u, resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (oci *ociClient) do(ctx context.Context, u, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	switch {
+	case oci.token != "":
+		req.Header.Set("Authorization", "Bearer "+oci.token)
+	case oci.auth != nil:
+		req.SetBasicAuth(oci.auth.Username, oci.auth.Password)
+	}
+
+	return oci.client.Do(req)
+}
+
+// authenticate fetches the bearer token as requested by the registry challenge.
+func (oci *ociClient) authenticate(ctx context.Context, challenge string) error {
+	scheme, params := parseChallenge(challenge)
+
+	if !strings.EqualFold(scheme, "bearer") {
+		return fmt.Errorf("unsupported registry authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return fmt.Errorf("failed to parse registry authentication realm %w", err)
+	}
+
+	query := realm.Query()
+
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	if oci.auth != nil {
+		req.SetBasicAuth(oci.auth.Username, oci.auth.Password)
+	}
+
+	resp, err := oci.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry token request failed: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode registry token %w", err)
+	}
+
+	oci.token = token.Token
+	if oci.token == "" {
+		oci.token = token.AccessToken
+	}
+
+	if oci.token == "" {
+		return fmt.Errorf("registry returned an empty token")
+	}
+
+	return nil
+}
+
+// parseChallenge parses WWW-Authenticate header, e.g. Bearer realm="https://ghcr.io/token",service="ghcr.io".
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+
+	for _, param := range strings.Split(parts[1], ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		params[strings.ToLower(kv[0])] = strings.Trim(kv[1], `"`)
+	}
+
+	return parts[0], params
+}
+
+// dockerConfigAuth reads registry credentials from the docker config file, nil is returned if there are none.
+func dockerConfigAuth(domain string) (*RegistryAuth, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil //nolint:nilerr
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var cfg struct {
+		Auths map[string]struct {
+			Auth     string `json:"auth"`
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"auths"`
+	}
+
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config %w", err)
+	}
+
+	key := domain
+	if domain == dockerHubDomain {
+		key = dockerHubAuthKey
+	}
+
+	entry, ok := cfg.Auths[key]
+	if !ok {
+		return nil, nil
+	}
+
+	if entry.Auth == "" {
+		return &RegistryAuth{Username: entry.Username, Password: entry.Password}, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode docker config auth for %s %w", domain, err)
+	}
+
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return nil, fmt.Errorf("malformed docker config auth for %s", domain)
+	}
+
+	return &RegistryAuth{Username: credentials[0], Password: credentials[1]}, nil
+}
+
+func registryHost(domain string) string {
+	if domain == dockerHubDomain {
+		return dockerHubRegistry
+	}
+
+	return domain
+}
@@ -0,0 +1,226 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/config"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+// clusterAPIModule is the cluster-api module path, its version is the default core provider version.
+const clusterAPIModule = "sigs.k8s.io/cluster-api"
+
+// Validate checks the options for conflicting or malformed settings.
+//
+// Validate doesn't access the management cluster, NewManager calls it first.
+//
+//nolint:gocyclo,cyclop
+func (o Options) Validate() error {
+	if o.ClusterctlConfigBytes != nil && o.ClusterctlConfigPath != "" {
+		return fmt.Errorf("clusterctl config path and config bytes are mutually exclusive")
+	}
+
+	if o.Proxy != nil && o.Kubeconfig.Path != "" {
+		return fmt.Errorf("proxy and kubeconfig are mutually exclusive")
+	}
+
+	if (o.EventRecorder == nil) != (o.EventObject == nil) {
+		return fmt.Errorf("event recorder and event object should be set together")
+	}
+
+	if o.WaitProviderTimeout < 0 || o.ConnectTimeout < 0 {
+		return fmt.Errorf("timeouts should not be negative")
+	}
+
+	if o.QPS < 0 || o.Burst < 0 {
+		return fmt.Errorf("QPS and burst should not be negative")
+	}
+
+	if o.CoreProvider != "" {
+		name, err := parseProvider(o.CoreProvider)
+		if err != nil {
+			return err
+		}
+
+		if name != constants.CoreProviderName {
+			return fmt.Errorf("unknown core provider %q, expected %s", name, constants.CoreProviderName)
+		}
+	}
+
+	for expectedType, providers := range map[clusterctlv1.ProviderType][]string{
+		clusterctlv1.BootstrapProviderType:    o.BootstrapProviders,
+		clusterctlv1.ControlPlaneProviderType: o.ControlPlaneProviders,
+	} {
+		for _, provider := range providers {
+			if _, err := parseProvider(provider); err != nil {
+				return err
+			}
+
+			if err := checkProviderType(provider, expectedType); err != nil {
+				return err
+			}
+		}
+	}
+
+	if o.CoreProvider != "" && len(o.InfrastructureProviders) == 0 && !o.CoreOnly {
+		return fmt.Errorf("no infrastructure providers are set, set CoreOnly to install only the core provider")
+	}
+
+	infrastructureProviders := map[string]struct{}{}
+
+	for i, provider := range o.InfrastructureProviders {
+		if provider == nil {
+			return fmt.Errorf("infrastructure provider %d is nil", i)
+		}
+
+		if _, ok := infrastructureProviders[provider.Name()]; ok {
+			return fmt.Errorf("infrastructure provider %s is set more than once", provider.Name())
+		}
+
+		infrastructureProviders[provider.Name()] = struct{}{}
+
+		if err := checkProviderType(provider.Name(), clusterctlv1.InfrastructureProviderType); err != nil {
+			return err
+		}
+
+		if err := infrastructure.ValidateVersion(provider.Version()); err != nil {
+			return fmt.Errorf("malformed infrastructure provider %s version %w", provider.Name(), err)
+		}
+	}
+
+	if _, err := infrastructure.InstallOrder(o.InfrastructureProviders); err != nil {
+		return err
+	}
+
+	for _, labels := range []map[string]string{o.LocalProviderPath, o.OCIProviderRepository} {
+		for label := range labels {
+			if _, _, err := parseProviderLabel(label); err != nil {
+				return err
+			}
+		}
+	}
+
+	for label := range o.ProviderResources {
+		if _, _, err := parseProviderLabel(label); err != nil {
+			return err
+		}
+	}
+
+	for label, replicas := range o.ProviderReplicas {
+		if _, _, err := parseProviderLabel(label); err != nil {
+			return err
+		}
+
+		if replicas < 0 {
+			return fmt.Errorf("provider %s replicas should not be negative", label)
+		}
+	}
+
+	for gate := range o.FeatureGates {
+		if _, ok := featureGateVariables[gate]; !ok {
+			return fmt.Errorf("unknown feature gate %q, supported feature gates: %s", gate, strings.Join(supportedFeatureGates(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// checkProviderType checks that the clusterctl built-in provider with the name has the expected type.
+//
+// Custom provider names which are not built into clusterctl are checked against the clusterctl config by validateProviderNames.
+func checkProviderType(provider string, expectedType clusterctlv1.ProviderType) error {
+	types, err := infrastructure.ProviderTypes(provider)
+	if err != nil {
+		return err
+	}
+
+	if len(types) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(types))
+
+	for _, providerType := range types {
+		if providerType == expectedType {
+			return nil
+		}
+
+		names = append(names, string(providerType))
+	}
+
+	return fmt.Errorf("provider %s is %s, it can't be used as %s", provider, strings.Join(names, " or "), expectedType)
+}
+
+// validateProviderNames checks that bootstrap and control plane providers are known to clusterctl config, NewManager calls it.
+func (o Options) validateProviderNames(configClient config.Client) error {
+	for providerType, providers := range map[clusterctlv1.ProviderType][]string{
+		clusterctlv1.BootstrapProviderType:    o.BootstrapProviders,
+		clusterctlv1.ControlPlaneProviderType: o.ControlPlaneProviders,
+	} {
+		for _, provider := range providers {
+			name, err := parseProvider(provider)
+			if err != nil {
+				return err
+			}
+
+			if _, err = configClient.Providers().Get(name, providerType); err != nil {
+				return fmt.Errorf("unknown %s provider %q %w", providerType, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseProvider validates name:version provider string and returns provider name.
+//
+// Version might be a version range, e.g. talos:>=0.5.0 <0.6.0.
+func parseProvider(provider string) (string, error) {
+	name, _, err := infrastructure.ParseProvider(provider)
+
+	return name, err
+}
+
+// defaultCoreProviderVersion pins the core provider without a version to the default version.
+func defaultCoreProviderVersion(provider string) string {
+	if provider == constants.CoreProviderName {
+		return provider + ":" + coreProviderDefaultVersion()
+	}
+
+	return provider
+}
+
+// coreProviderDefaultVersion returns the cluster-api module version capi-utils is built with.
+//
+// constants.CoreProviderDefaultVersion is used if the build info is not available.
+func coreProviderDefaultVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return constants.CoreProviderDefaultVersion
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != clusterAPIModule {
+			continue
+		}
+
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+
+		if dep.Version != "" && dep.Version != "(devel)" {
+			return dep.Version
+		}
+	}
+
+	return constants.CoreProviderDefaultVersion
+}
@@ -0,0 +1,35 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"testing"
+
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+)
+
+func TestCheckProviderType(t *testing.T) {
+	for _, tt := range []struct {
+		provider     string
+		expectedType clusterctlv1.ProviderType
+		err          bool
+	}{
+		{provider: "talos:v0.5.3", expectedType: clusterctlv1.BootstrapProviderType},
+		{provider: "talos:v0.4.5", expectedType: clusterctlv1.ControlPlaneProviderType},
+		{provider: "aws:v1.2.0", expectedType: clusterctlv1.InfrastructureProviderType},
+		{provider: "aws:v1.2.0", expectedType: clusterctlv1.BootstrapProviderType, err: true},
+		{provider: "kubeadm", expectedType: clusterctlv1.InfrastructureProviderType, err: true},
+		{provider: "custom:v0.1.0", expectedType: clusterctlv1.BootstrapProviderType},
+		{provider: ":v0.1.0", expectedType: clusterctlv1.BootstrapProviderType, err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.provider+" "+string(tt.expectedType), func(t *testing.T) {
+			if err := checkProviderType(tt.provider, tt.expectedType); (err != nil) != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+// ProgressPhase is the install phase which was completed.
+type ProgressPhase string
+
+// Install phases reported to the Options.ProgressFunc.
+const (
+	ProgressPhaseCoreInstalled     ProgressPhase = "CoreInstalled"
+	ProgressPhaseCertManagerReady  ProgressPhase = "CertManagerReady"
+	ProgressPhaseProviderInstalled ProgressPhase = "ProviderInstalled"
+	ProgressPhaseProviderReady     ProgressPhase = "ProviderReady"
+	ProgressPhaseCompleted         ProgressPhase = "Completed"
+)
+
+// ProgressEvent is reported at each install phase boundary.
+type ProgressEvent struct {
+	Phase ProgressPhase
+	// Provider is set for the provider specific phases.
+	Provider string
+}
+
+// progress calls ProgressFunc if it is set.
+//
+// It is always called from the goroutine running Install, so callbacks don't need locking.
+func (clusterAPI *Manager) progress(phase ProgressPhase, provider string) {
+	if clusterAPI.options.ProgressFunc == nil {
+		return
+	}
+
+	clusterAPI.options.ProgressFunc(ProgressEvent{
+		Phase:    phase,
+		Provider: provider,
+	})
+}
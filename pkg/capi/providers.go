@@ -0,0 +1,438 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+)
+
+// ProviderNamespaces maps installed provider instances to the namespaces they watch.
+//
+// Keys are clusterctl instance names in the form <controller namespace>/<provider label>,
+// empty value means that the provider watches all namespaces.
+func (clusterAPI *Manager) ProviderNamespaces(ctx context.Context) (map[string]string, error) {
+	providers, err := clusterAPI.listProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(providers))
+
+	for _, provider := range providers {
+		watchNamespace := provider.WatchedNamespace
+
+		if watchNamespace == "" {
+			label := clusterctlv1.ManifestLabel(provider.ProviderName, provider.GetProviderType())
+
+			if watchNamespace, err = clusterAPI.watchNamespace(ctx, label, provider.Namespace); err != nil {
+				return nil, err
+			}
+		}
+
+		res[provider.InstanceName()] = watchNamespace
+	}
+
+	return res, nil
+}
+
+// ProviderImages maps installed providers to the container images of their controller deployments.
+//
+// Keys are clusterctl provider labels, e.g. cluster-api, bootstrap-talos, infrastructure-aws.
+func (clusterAPI *Manager) ProviderImages(ctx context.Context) (map[string][]string, error) {
+	providers, err := clusterAPI.listProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]string, len(providers))
+
+	for _, provider := range providers {
+		label := clusterctlv1.ManifestLabel(provider.ProviderName, provider.GetProviderType())
+
+		deployments, err := clusterAPI.providerDeployments(ctx, label)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, deployment := range deployments {
+			for _, container := range deployment.Spec.Template.Spec.InitContainers {
+				res[label] = append(res[label], container.Image)
+			}
+
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				res[label] = append(res[label], container.Image)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// Providers returns clusterctl provider inventory for all installed providers.
+func (clusterAPI *Manager) Providers(ctx context.Context) ([]clusterctlv1.Provider, error) {
+	return clusterAPI.listProviders(ctx)
+}
+
+// listProviders fetches clusterctl provider inventory.
+func (clusterAPI *Manager) listProviders(ctx context.Context) ([]clusterctlv1.Provider, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err := clusterAPI.runtimeClient.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list providers %w", err)
+	}
+
+	providers := make([]clusterctlv1.Provider, len(list.Items))
+
+	for i := range list.Items {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(list.Items[i].Object, &providers[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return providers, nil
+}
+
+// installedProviderVersion reads the provider version from the clusterctl inventory.
+func (clusterAPI *Manager) installedProviderVersion(ctx context.Context, name string, providerType clusterctlv1.ProviderType) (string, error) {
+	providers, err := clusterAPI.listProviders(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, provider := range providers {
+		if provider.ProviderName == name && provider.GetProviderType() == providerType {
+			return provider.Version, nil
+		}
+	}
+
+	return "", fmt.Errorf("provider %s of type %s is not found in the inventory", name, providerType)
+}
+
+// providerDeployments lists controller deployments of the provider identified by the clusterctl manifest label.
+func (clusterAPI *Manager) providerDeployments(ctx context.Context, label string) ([]appsv1.Deployment, error) {
+	deployments, err := clusterAPI.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", clusterv1.ProviderLabelName, label),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deployments.Items) == 0 {
+		return nil, fmt.Errorf("no deployments found for provider %s", label)
+	}
+
+	return deployments.Items, nil
+}
+
+// patchProviderResources sets resource requirements on all containers of the provider controller deployments.
+func (clusterAPI *Manager) patchProviderResources(ctx context.Context) error {
+	for label, resources := range clusterAPI.options.ProviderResources {
+		deployments, err := clusterAPI.providerDeployments(ctx, label)
+		if err != nil {
+			return err
+		}
+
+		for i := range deployments {
+			deployment := &deployments[i]
+
+			for j := range deployment.Spec.Template.Spec.Containers {
+				deployment.Spec.Template.Spec.Containers[j].Resources = *resources.DeepCopy()
+			}
+
+			if deployment, err = clusterAPI.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+
+			if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// patchProviderReplicas scales the provider controller deployments.
+func (clusterAPI *Manager) patchProviderReplicas(ctx context.Context) error {
+	for label, replicas := range clusterAPI.options.ProviderReplicas {
+		deployments, err := clusterAPI.providerDeployments(ctx, label)
+		if err != nil {
+			return err
+		}
+
+		for i := range deployments {
+			deployment := &deployments[i]
+
+			if replicas > 1 && !leaderElectionEnabled(deployment) {
+				fmt.Printf("warning: provider %s deployment %s/%s runs %d replicas without leader election enabled\n", label, deployment.Namespace, deployment.Name, replicas)
+			}
+
+			deployment.Spec.Replicas = &replicas
+
+			if deployment, err = clusterAPI.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+				return err
+			}
+
+			if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// patchProviderAnnotations sets the configured annotations on the provider namespaces and controller deployments.
+func (clusterAPI *Manager) patchProviderAnnotations(ctx context.Context) error {
+	if len(clusterAPI.options.ProviderNamespaceAnnotations) > 0 {
+		providers, err := clusterAPI.listProviders(ctx)
+		if err != nil {
+			return err
+		}
+
+		namespaces := map[string]struct{}{}
+
+		for _, provider := range providers {
+			namespaces[provider.Namespace] = struct{}{}
+		}
+
+		for name := range namespaces {
+			var namespace *corev1.Namespace
+
+			if namespace, err = clusterAPI.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{}); err != nil {
+				return err
+			}
+
+			annotations, changed := mergeAnnotations(namespace.Annotations, clusterAPI.options.ProviderNamespaceAnnotations)
+			if !changed {
+				continue
+			}
+
+			namespace.Annotations = annotations
+
+			if _, err = clusterAPI.clientset.CoreV1().Namespaces().Update(ctx, namespace, metav1.UpdateOptions{}); err != nil {
+				return fmt.Errorf("failed to annotate namespace %s %w", name, err)
+			}
+		}
+	}
+
+	if len(clusterAPI.options.ProviderDeploymentAnnotations) == 0 {
+		return nil
+	}
+
+	deployments, err := clusterAPI.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
+		LabelSelector: clusterv1.ProviderLabelName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+
+		annotations, changed := mergeAnnotations(deployment.Annotations, clusterAPI.options.ProviderDeploymentAnnotations)
+		deployment.Annotations = annotations
+
+		podAnnotations, podChanged := mergeAnnotations(deployment.Spec.Template.Annotations, clusterAPI.options.ProviderDeploymentAnnotations)
+		deployment.Spec.Template.Annotations = podAnnotations
+
+		if !changed && !podChanged {
+			continue
+		}
+
+		if deployment, err = clusterAPI.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to annotate deployment %s/%s %w", deployments.Items[i].Namespace, deployments.Items[i].Name, err)
+		}
+
+		// changed pod template annotations restart the controller pods
+		if podChanged {
+			if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeAnnotations adds the extra annotations and reports if any of them was missing or had a different value.
+func mergeAnnotations(annotations, extra map[string]string) (map[string]string, bool) {
+	changed := false
+
+	for key, value := range extra {
+		if current, ok := annotations[key]; ok && current == value {
+			continue
+		}
+
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		annotations[key] = value
+		changed = true
+	}
+
+	return annotations, changed
+}
+
+// leaderElectionEnabled checks if any container of the deployment has leader election flag set.
+func leaderElectionEnabled(deployment *appsv1.Deployment) bool {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, arg := range append(append([]string{}, container.Command...), container.Args...) {
+			switch arg {
+			case "--leader-elect", "--leader-elect=true", "--enable-leader-election", "--enable-leader-election=true":
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+const (
+	// providerManagerContainer is the name of the controller container in the provider deployments.
+	providerManagerContainer = "manager"
+
+	// watchNamespaceFlag is the provider controller flag which limits reconciled objects to a single namespace.
+	watchNamespaceFlag = "--namespace"
+)
+
+// setWatchNamespace scopes the provider controllers installed in the namespace to reconcile objects only in the watched namespace.
+//
+// Empty watched namespace makes the controllers reconcile objects in all namespaces.
+func (clusterAPI *Manager) setWatchNamespace(ctx context.Context, label, namespace, watchNamespace string) error {
+	deployments, err := clusterAPI.providerDeployments(ctx, label)
+	if err != nil {
+		return err
+	}
+
+	for i := range deployments {
+		deployment := &deployments[i]
+
+		if deployment.Namespace != namespace {
+			continue
+		}
+
+		for j := range deployment.Spec.Template.Spec.Containers {
+			container := &deployment.Spec.Template.Spec.Containers[j]
+
+			if container.Name != providerManagerContainer {
+				continue
+			}
+
+			args := make([]string, 0, len(container.Args)+1)
+
+			for k := 0; k < len(container.Args); k++ {
+				switch {
+				case container.Args[k] == watchNamespaceFlag:
+					k++
+				case strings.HasPrefix(container.Args[k], watchNamespaceFlag+"="):
+				default:
+					args = append(args, container.Args[k])
+				}
+			}
+
+			if watchNamespace != "" {
+				args = append(args, fmt.Sprintf("%s=%s", watchNamespaceFlag, watchNamespace))
+			}
+
+			container.Args = args
+		}
+
+		if deployment, err = clusterAPI.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+
+		if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// watchNamespace returns the namespace the provider controllers installed in the namespace are scoped to.
+//
+// Empty string means that the controllers watch all namespaces.
+func (clusterAPI *Manager) watchNamespace(ctx context.Context, label, namespace string) (string, error) {
+	deployments, err := clusterAPI.providerDeployments(ctx, label)
+	if err != nil {
+		return "", err
+	}
+
+	for _, deployment := range deployments {
+		if deployment.Namespace != namespace {
+			continue
+		}
+
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name != providerManagerContainer {
+				continue
+			}
+
+			for k, arg := range container.Args {
+				if arg == watchNamespaceFlag && k+1 < len(container.Args) {
+					return container.Args[k+1], nil
+				}
+
+				if strings.HasPrefix(arg, watchNamespaceFlag+"=") {
+					return strings.TrimPrefix(arg, watchNamespaceFlag+"="), nil
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// waitDeploymentRollout waits until the deployment has all replicas updated and available.
+func (clusterAPI *Manager) waitDeploymentRollout(ctx context.Context, namespace, name string) error {
+	return retry.Constant(10*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		deployment, err := clusterAPI.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return clusterAPI.retryable(err)
+		}
+
+		var replicas int32 = 1
+
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+
+		status := deployment.Status
+
+		switch {
+		case status.ObservedGeneration < deployment.Generation:
+			return retry.ExpectedError(fmt.Errorf("deployment %s/%s rollout is not observed yet", namespace, name))
+		case status.UpdatedReplicas != replicas:
+			err = fmt.Errorf("deployment %s/%s %d of %d replicas updated", namespace, name, status.UpdatedReplicas, replicas)
+		case status.AvailableReplicas != replicas || status.Replicas != replicas:
+			err = fmt.Errorf("deployment %s/%s %d of %d replicas available", namespace, name, status.AvailableReplicas, replicas)
+		default:
+			return nil
+		}
+
+		if warning, e := infrastructure.DeploymentWarning(ctx, clusterAPI.clientset, deployment); e == nil && warning != "" {
+			err = fmt.Errorf("%w, last warning: %s", err, warning)
+		}
+
+		return retry.ExpectedError(err)
+	})
+}
@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeAnnotations(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		annotations map[string]string
+		extra       map[string]string
+		expected    map[string]string
+		changed     bool
+	}{
+		{
+			name:     "no annotations",
+			extra:    map[string]string{"a": "1"},
+			expected: map[string]string{"a": "1"},
+			changed:  true,
+		},
+		{
+			name:        "up to date",
+			annotations: map[string]string{"a": "1", "b": "2"},
+			extra:       map[string]string{"a": "1"},
+			expected:    map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:        "different value",
+			annotations: map[string]string{"a": "1", "b": "2"},
+			extra:       map[string]string{"a": "3"},
+			expected:    map[string]string{"a": "3", "b": "2"},
+			changed:     true,
+		},
+		{
+			name:        "no extra",
+			annotations: map[string]string{"a": "1"},
+			expected:    map[string]string{"a": "1"},
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			res, changed := mergeAnnotations(tt.annotations, tt.extra)
+
+			if changed != tt.changed {
+				t.Errorf("expected changed %v, got %v", tt.changed, changed)
+			}
+
+			if !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
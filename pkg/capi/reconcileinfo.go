@@ -0,0 +1,133 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+)
+
+// ProviderReconcileInfo reports how long the provider has been installed and healthy.
+//
+// clusterctl Provider inventory objects have no conditions, so the Available condition transitions of the provider
+// controller deployments are the only health signal.
+type ProviderReconcileInfo struct {
+	// Name is the clusterctl instance name in the form <namespace>/<provider label>.
+	Name    string
+	Version string
+
+	// InstalledAt is the creation time of the provider inventory object.
+	InstalledAt time.Time
+
+	// AvailableSince is the latest time the controller deployments became available, zero if any of them is not available.
+	AvailableSince time.Time
+}
+
+// Age returns the time since the provider install.
+func (info *ProviderReconcileInfo) Age() time.Duration {
+	return time.Since(info.InstalledAt)
+}
+
+// HealthyFor returns the time since the provider controllers are available.
+//
+// Zero means that the provider is not healthy, short durations point to the recently flapping controllers.
+func (info *ProviderReconcileInfo) HealthyFor() time.Duration {
+	if info.AvailableSince.IsZero() {
+		return 0
+	}
+
+	return time.Since(info.AvailableSince)
+}
+
+// ProviderReconcileInfo reports install time and controller availability of all installed providers.
+func (clusterAPI *Manager) ProviderReconcileInfo(ctx context.Context) ([]ProviderReconcileInfo, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err := clusterAPI.runtimeClient.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("failed to list providers %w", err)
+	}
+
+	res := make([]ProviderReconcileInfo, 0, len(list.Items))
+
+	for _, provider := range list.Items {
+		providerName, _, err := unstructured.NestedString(provider.Object, "providerName")
+		if err != nil {
+			return nil, err
+		}
+
+		providerType, _, err := unstructured.NestedString(provider.Object, "type")
+		if err != nil {
+			return nil, err
+		}
+
+		providerVersion, _, err := unstructured.NestedString(provider.Object, "version")
+		if err != nil {
+			return nil, err
+		}
+
+		label := clusterctlv1.ManifestLabel(providerName, clusterctlv1.ProviderType(providerType))
+
+		availableSince, err := clusterAPI.providerAvailableSince(ctx, label, provider.GetNamespace())
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, ProviderReconcileInfo{
+			Name:           types.NamespacedName{Namespace: provider.GetNamespace(), Name: label}.String(),
+			Version:        providerVersion,
+			InstalledAt:    provider.GetCreationTimestamp().Time,
+			AvailableSince: availableSince,
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// providerAvailableSince returns the latest Available condition transition of the provider controller deployments in the namespace.
+func (clusterAPI *Manager) providerAvailableSince(ctx context.Context, label, namespace string) (time.Time, error) {
+	deployments, err := clusterAPI.providerDeployments(ctx, label)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var since time.Time
+
+	for _, deployment := range deployments {
+		if deployment.Namespace != namespace {
+			continue
+		}
+
+		available := false
+
+		for _, cond := range deployment.Status.Conditions {
+			if cond.Type != appsv1.DeploymentAvailable || cond.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			available = true
+
+			if cond.LastTransitionTime.After(since) {
+				since = cond.LastTransitionTime.Time
+			}
+		}
+
+		if !available {
+			return time.Time{}, nil
+		}
+	}
+
+	return since, nil
+}
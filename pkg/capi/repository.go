@@ -0,0 +1,507 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v33/github"
+	"golang.org/x/oauth2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/version"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/repository"
+
+	"github.com/talos-systems/capi-utils/pkg/capi/infrastructure"
+	"github.com/talos-systems/capi-utils/pkg/constants"
+)
+
+// ProviderMetadata is the provider metadata.yaml contents resolved for a specific version.
+type ProviderMetadata struct {
+	Name          string
+	Type          clusterctlv1.ProviderType
+	Version       string
+	Contract      string
+	ReleaseSeries []clusterctlv1.ReleaseSeries
+}
+
+// ProviderMetadata reads provider metadata from the provider repository (or the local provider directory).
+//
+// Provider is identified by the clusterctl label, e.g. cluster-api, bootstrap-talos, infrastructure-aws.
+// Metadata is resolved for the requested provider version, installed version or the latest available version.
+func (clusterAPI *Manager) ProviderMetadata(ctx context.Context, label string) (*ProviderMetadata, error) {
+	name, providerType, err := parseProviderLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := clusterAPI.providerRepository(name, providerType)
+	if err != nil {
+		return nil, err
+	}
+
+	providerVersion, err := clusterAPI.resolveProviderVersion(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := repo.Metadata(providerVersion).Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider %s metadata %w", label, err)
+	}
+
+	v, err := version.ParseSemantic(providerVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ProviderMetadata{
+		Name:          name,
+		Type:          providerType,
+		Version:       providerVersion,
+		ReleaseSeries: metadata.ReleaseSeries,
+	}
+
+	if series := metadata.GetReleaseSeriesForVersion(v); series != nil {
+		res.Contract = series.Contract
+	}
+
+	return res, nil
+}
+
+// AvailableVersions lists versions published in the provider repository sorted from oldest to newest.
+//
+// Provider is identified by the clusterctl label, repository overrides from the clusterctl config
+// and LocalProviderPath are respected.
+func (clusterAPI *Manager) AvailableVersions(ctx context.Context, label string) ([]string, error) {
+	name, providerType, err := parseProviderLabel(label)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := clusterAPI.providerRepository(name, providerType)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := repo.GetVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provider %s versions %w", label, err)
+	}
+
+	parsed := make([]*version.Version, 0, len(versions))
+	res := make([]string, 0, len(versions))
+
+	for _, s := range versions {
+		v, err := version.ParseSemantic(s)
+		if err != nil {
+			continue
+		}
+
+		parsed = append(parsed, v)
+		res = append(res, s)
+	}
+
+	sort.Sort(versionSorter{versions: parsed, names: res})
+
+	return res, nil
+}
+
+const (
+	templateFilePrefix = "cluster-template"
+	templateFileExt    = ".yaml"
+)
+
+// TemplateFlavors lists cluster template flavors published in the infrastructure provider repository.
+//
+// Flavors are listed for the requested, installed or latest provider version,
+// the default template (cluster-template.yaml) is listed as an empty flavor.
+func (clusterAPI *Manager) TemplateFlavors(ctx context.Context, providerName string) ([]string, error) {
+	repo, err := clusterAPI.providerRepository(providerName, clusterctlv1.InfrastructureProviderType)
+	if err != nil {
+		return nil, err
+	}
+
+	providerVersion, err := clusterAPI.resolveProviderVersion(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := clusterAPI.repositoryFiles(ctx, repo, providerVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provider %s templates %w", providerName, err)
+	}
+
+	res := []string{}
+
+	for _, file := range files {
+		if !strings.HasPrefix(file, templateFilePrefix) || !strings.HasSuffix(file, templateFileExt) {
+			continue
+		}
+
+		flavor := strings.TrimSuffix(strings.TrimPrefix(file, templateFilePrefix), templateFileExt)
+
+		switch {
+		case flavor == "":
+			res = append(res, "")
+		case strings.HasPrefix(flavor, "-"):
+			res = append(res, strings.TrimPrefix(flavor, "-"))
+		}
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// repositoryFiles lists files of the provider release, both local and GitHub repositories are supported.
+func (clusterAPI *Manager) repositoryFiles(ctx context.Context, repo repository.Client, providerVersion string) ([]string, error) {
+	u, err := url.Parse(repo.URL())
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		// local repository layout is {basepath}/{provider-label}/{version}/components.yaml
+		dir := filepath.Join(filepath.Dir(filepath.Dir(u.Path)), providerVersion)
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]string, 0, len(entries))
+
+		for _, entry := range entries {
+			res = append(res, entry.Name())
+		}
+
+		return res, nil
+	case "https":
+		// GitHub repository URL is https://github.com/{owner}/{repository}/releases/{version}/components.yaml
+		parts := strings.Split(strings.TrimPrefix(u.Path, "/"), "/")
+
+		if u.Host != "github.com" || len(parts) < 4 || parts[2] != "releases" {
+			return nil, fmt.Errorf("listing files is not supported for repository %s", repo.URL())
+		}
+
+		httpClient := http.DefaultClient
+
+		if token, err := clusterAPI.configClient.Variables().Get("GITHUB_TOKEN"); err == nil && token != "" {
+			httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+		}
+
+		release, _, err := github.NewClient(httpClient).Repositories.GetReleaseByTag(ctx, parts[0], parts[1], providerVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		res := make([]string, 0, len(release.Assets))
+
+		for _, asset := range release.Assets {
+			res = append(res, asset.GetName())
+		}
+
+		return res, nil
+	default:
+		return nil, fmt.Errorf("listing files is not supported for repository %s", repo.URL())
+	}
+}
+
+type versionSorter struct {
+	versions []*version.Version
+	names    []string
+}
+
+func (s versionSorter) Len() int           { return len(s.versions) }
+func (s versionSorter) Less(i, j int) bool { return s.versions[i].LessThan(s.versions[j]) }
+func (s versionSorter) Swap(i, j int) {
+	s.versions[i], s.versions[j] = s.versions[j], s.versions[i]
+	s.names[i], s.names[j] = s.names[j], s.names[i]
+}
+
+func (clusterAPI *Manager) providerRepository(name string, providerType clusterctlv1.ProviderType) (repository.Client, error) {
+	providerConfig, err := clusterAPI.configClient.Providers().Get(name, providerType)
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.New(providerConfig, clusterAPI.configClient)
+}
+
+// resolveProviderVersion picks requested, installed or latest provider version in that order.
+func (clusterAPI *Manager) resolveProviderVersion(ctx context.Context, repo repository.Client) (string, error) {
+	if v := clusterAPI.requestedVersion(repo.Name(), repo.Type()); v != "" {
+		if infrastructure.IsVersionRange(v) {
+			return clusterAPI.resolveVersionRange(repo.Name(), repo.Type(), v)
+		}
+
+		return v, nil
+	}
+
+	if clusterAPI.version != "" {
+		providers, err := clusterAPI.listProviders(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		for _, provider := range providers {
+			if provider.ProviderName == repo.Name() && provider.GetProviderType() == repo.Type() {
+				return provider.Version, nil
+			}
+		}
+	}
+
+	versions, err := repo.GetVersions()
+	if err != nil {
+		return "", err
+	}
+
+	return latestVersion(versions)
+}
+
+// latestVersion returns the highest released version, pre-releases are ignored.
+func latestVersion(versions []string) (string, error) {
+	var latest *version.Version
+
+	res := ""
+
+	for _, s := range versions {
+		v, err := version.ParseSemantic(s)
+		if err != nil || v.PreRelease() != "" {
+			continue
+		}
+
+		if latest == nil || latest.LessThan(v) {
+			latest = v
+			res = s
+		}
+	}
+
+	if latest == nil {
+		return "", fmt.Errorf("no released versions found")
+	}
+
+	return res, nil
+}
+
+// resolveVersionRanges replaces provider version ranges with the highest matching version published in the provider repository.
+func (clusterAPI *Manager) resolveVersionRanges() error {
+	resolve := func(provider string, providerType clusterctlv1.ProviderType) (string, error) {
+		name, requested, err := infrastructure.ParseProvider(provider)
+		if err != nil {
+			return "", err
+		}
+
+		if !infrastructure.IsVersionRange(requested) {
+			return provider, nil
+		}
+
+		resolved, err := clusterAPI.resolveVersionRange(name, providerType, requested)
+		if err != nil {
+			return "", err
+		}
+
+		return name + ":" + resolved, nil
+	}
+
+	var err error
+
+	if clusterAPI.options.CoreProvider != "" {
+		if clusterAPI.options.CoreProvider, err = resolve(clusterAPI.options.CoreProvider, clusterctlv1.CoreProviderType); err != nil {
+			return err
+		}
+	}
+
+	for _, list := range []struct {
+		providers    *[]string
+		providerType clusterctlv1.ProviderType
+	}{
+		{&clusterAPI.options.BootstrapProviders, clusterctlv1.BootstrapProviderType},
+		{&clusterAPI.options.ControlPlaneProviders, clusterctlv1.ControlPlaneProviderType},
+	} {
+		// copy the slice, so that the caller's options are not modified
+		resolved := make([]string, len(*list.providers))
+
+		for i, provider := range *list.providers {
+			if resolved[i], err = resolve(provider, list.providerType); err != nil {
+				return err
+			}
+		}
+
+		*list.providers = resolved
+	}
+
+	for _, provider := range clusterAPI.options.InfrastructureProviders {
+		if !infrastructure.IsVersionRange(provider.Version()) {
+			continue
+		}
+
+		if _, ok := clusterAPI.resolvedVersions[provider.Name()]; ok {
+			continue
+		}
+
+		var resolved string
+
+		if resolved, err = clusterAPI.resolveVersionRange(provider.Name(), clusterctlv1.InfrastructureProviderType, provider.Version()); err != nil {
+			return err
+		}
+
+		if clusterAPI.resolvedVersions == nil {
+			clusterAPI.resolvedVersions = map[string]string{}
+		}
+
+		clusterAPI.resolvedVersions[provider.Name()] = resolved
+	}
+
+	return nil
+}
+
+// checkCoreProviderContract verifies that the core provider version supports the contract of the pinned infrastructure provider versions.
+//
+// Infrastructure providers without a version are skipped, clusterctl picks their latest version for the core provider contract.
+// If the core provider is not set, the version installed into the management cluster is checked.
+func (clusterAPI *Manager) checkCoreProviderContract(ctx context.Context) error {
+	coreVersion := clusterAPI.requestedVersion(constants.CoreProviderName, clusterctlv1.CoreProviderType)
+
+	if clusterAPI.options.CoreProvider == "" {
+		var err error
+
+		if coreVersion, err = clusterAPI.installedCoreVersion(ctx); err != nil {
+			return err
+		}
+
+		// core provider is not installed yet, so there is nothing to check
+		if coreVersion == "" {
+			return nil
+		}
+	}
+
+	coreContract, err := clusterAPI.providerContract(constants.CoreProviderName, clusterctlv1.CoreProviderType, coreVersion)
+	if err != nil {
+		return err
+	}
+
+	for _, provider := range clusterAPI.options.InfrastructureProviders {
+		providerVersion := clusterAPI.requestedVersion(provider.Name(), clusterctlv1.InfrastructureProviderType)
+		if providerVersion == "" {
+			continue
+		}
+
+		var contract string
+
+		if contract, err = clusterAPI.providerContract(provider.Name(), clusterctlv1.InfrastructureProviderType, providerVersion); err != nil {
+			return err
+		}
+
+		if contract != coreContract {
+			return fmt.Errorf("core provider %s %s supports contract %s, but infrastructure provider %s %s requires %s",
+				constants.CoreProviderName, coreVersion, coreContract, provider.Name(), providerVersion, contract)
+		}
+	}
+
+	return nil
+}
+
+// installedCoreVersion reads the core provider version from the clusterctl inventory, empty string is returned if it's not installed.
+func (clusterAPI *Manager) installedCoreVersion(ctx context.Context) (string, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(clusterAPI.providerGVK())
+
+	if err := clusterAPI.runtimeClient.List(ctx, list); err != nil {
+		if meta.IsNoMatchError(err) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to list providers %w", err)
+	}
+
+	for _, item := range list.Items {
+		providerType, _, err := unstructured.NestedString(item.Object, "type")
+		if err != nil {
+			return "", err
+		}
+
+		if clusterctlv1.ProviderType(providerType) != clusterctlv1.CoreProviderType {
+			continue
+		}
+
+		coreVersion, _, err := unstructured.NestedString(item.Object, "version")
+
+		return coreVersion, err
+	}
+
+	return "", nil
+}
+
+// providerContract reads the contract of the provider version from the provider metadata.
+func (clusterAPI *Manager) providerContract(name string, providerType clusterctlv1.ProviderType, providerVersion string) (string, error) {
+	label := clusterctlv1.ManifestLabel(name, providerType)
+
+	repo, err := clusterAPI.providerRepository(name, providerType)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := repo.Metadata(providerVersion).Get()
+	if err != nil {
+		return "", fmt.Errorf("failed to read provider %s metadata %w", label, err)
+	}
+
+	v, err := version.ParseSemantic(providerVersion)
+	if err != nil {
+		return "", err
+	}
+
+	series := metadata.GetReleaseSeriesForVersion(v)
+	if series == nil {
+		return "", fmt.Errorf("provider %s version %s is not listed in the provider metadata release series", label, providerVersion)
+	}
+
+	return series.Contract, nil
+}
+
+// resolveVersionRange picks the highest provider version matching the range and checks it supports the current contract.
+func (clusterAPI *Manager) resolveVersionRange(name string, providerType clusterctlv1.ProviderType, requested string) (string, error) {
+	label := clusterctlv1.ManifestLabel(name, providerType)
+
+	repo, err := clusterAPI.providerRepository(name, providerType)
+	if err != nil {
+		return "", err
+	}
+
+	versions, err := repo.GetVersions()
+	if err != nil {
+		return "", fmt.Errorf("failed to list provider %s versions %w", label, err)
+	}
+
+	resolved, err := infrastructure.ResolveVersion(requested, versions)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve provider %s version %w", label, err)
+	}
+
+	contract, err := clusterAPI.providerContract(name, providerType, resolved)
+	if err != nil {
+		return "", err
+	}
+
+	if contract != clusterv1.GroupVersion.Version {
+		return "", fmt.Errorf("provider %s version %s supports contract %s, but %s is required", label, resolved, contract, clusterv1.GroupVersion.Version)
+	}
+
+	fmt.Printf("resolved provider %s version range %q to %s\n", label, requested, resolved)
+
+	return resolved, nil
+}
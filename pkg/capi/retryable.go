@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/talos-systems/go-retry/retry"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultRetryableError classifies connection errors, timeouts, conflicts, throttling and server errors as transient.
+//
+// Other client errors (4xx) are considered fatal.
+func DefaultRetryableError(err error) bool {
+	var status apierrors.APIStatus
+
+	if errors.As(err, &status) {
+		code := status.Status().Code
+
+		return code >= http.StatusInternalServerError || code == http.StatusConflict || code == http.StatusTooManyRequests ||
+			apierrors.IsServerTimeout(err) || apierrors.IsTimeout(err)
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryable marks transient errors as expected, so that wait loops retry them.
+//
+// Errors are classified with Options.RetryableErrorFunc or DefaultRetryableError, other errors are returned as is.
+func (clusterAPI *Manager) retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if clusterAPI.retryableErrorFunc()(err) {
+		return retry.ExpectedError(err)
+	}
+
+	return err
+}
+
+// retryableErrorFunc returns Options.RetryableErrorFunc or DefaultRetryableError if it's not set.
+func (clusterAPI *Manager) retryableErrorFunc() func(error) bool {
+	if clusterAPI.options.RetryableErrorFunc != nil {
+		return clusterAPI.options.RetryableErrorFunc
+	}
+
+	return DefaultRetryableError
+}
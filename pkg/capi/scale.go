@@ -26,6 +26,8 @@ const (
 
 // ScaleOptions defines additional optional parameters for scale method.
 type ScaleOptions struct {
+	OperationOptions
+
 	MachineDeploymentName string
 }
 
@@ -40,6 +42,13 @@ func MachineDeploymentName(name string) ScaleOption {
 	}
 }
 
+// ScaleDryRun validates the scale request with server-side dry-run without persisting it, like DryRun does.
+func ScaleDryRun() ScaleOption {
+	return func(opts *ScaleOptions) {
+		opts.DryRun = true
+	}
+}
+
 // Scale cluster nodes.
 //nolint:gocognit,gocyclo,cyclop
 func (cluster *Cluster) Scale(ctx context.Context, replicas int, nodes NodeGroup, setters ...ScaleOption) error {
@@ -102,9 +111,21 @@ func (cluster *Cluster) Scale(ctx context.Context, replicas int, nodes NodeGroup
 		return nil
 	}
 
+	current := spec["replicas"]
+
 	spec["replicas"] = replicas
 	object.Object["spec"] = spec
 
+	if opts.DryRun {
+		if err = cluster.manager.runtimeClient.Update(ctx, object, opts.updateOptions()...); err != nil {
+			return err
+		}
+
+		fmt.Printf("dry run: %s %s would be scaled from %v to %d replicas\n", object.GetKind(), object.GetName(), current, replicas)
+
+		return nil
+	}
+
 	if err = cluster.manager.runtimeClient.Update(ctx, object); err != nil {
 		return err
 	}
@@ -116,7 +137,7 @@ func (cluster *Cluster) Scale(ctx context.Context, replicas int, nodes NodeGroup
 
 	err = retry.Constant(30*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
 		if e := cluster.manager.runtimeClient.Get(ctx, types.NamespacedName{Name: object.GetName(), Namespace: object.GetNamespace()}, object); e != nil {
-			return e
+			return cluster.manager.retryable(e)
 		}
 
 		if c := getReplicas(object, "replicas"); c != int64(replicas) {
@@ -124,11 +145,11 @@ func (cluster *Cluster) Scale(ctx context.Context, replicas int, nodes NodeGroup
 		}
 
 		if e := cluster.manager.CheckClusterReady(ctx, cluster); e != nil {
-			return e
+			return cluster.manager.retryable(e)
 		}
 
 		if e := cluster.Sync(ctx); e != nil {
-			return e
+			return cluster.manager.retryable(e)
 		}
 
 		var actualReplicas int
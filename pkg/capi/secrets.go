@@ -0,0 +1,187 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// clusterSecretSuffixes are the suffixes of the secrets created for the cluster by CAPI and the providers.
+var clusterSecretSuffixes = []string{"kubeconfig", "talosconfig", "ca", "etcd", "sa", "proxy"}
+
+// SecretInfo describes a secret created for the cluster.
+type SecretInfo struct {
+	Name string
+	Type corev1.SecretType
+
+	// Purpose is the secret name suffix, e.g. kubeconfig, talosconfig, ca, etcd, sa, proxy.
+	// Purpose is empty for other secrets labeled with the cluster name.
+	Purpose string
+
+	CreatedAt time.Time
+	Age       time.Duration
+
+	// NotAfter is the earliest expiration time of the PEM certificates stored in the secret, nil if there are no certificates.
+	NotAfter *time.Time
+}
+
+// ExpiresWithin returns true if any certificate in the secret expires within the duration.
+func (info SecretInfo) ExpiresWithin(d time.Duration) bool {
+	return info.NotAfter != nil && time.Until(*info.NotAfter) < d
+}
+
+// Secrets lists the secrets CAPI and the providers created for the cluster sorted by name.
+//
+// Secrets are found by the cluster name label, secrets with the well-known name suffixes are included
+// only if they are owned by the Cluster.
+func (cluster *Cluster) Secrets(ctx context.Context) ([]SecretInfo, error) {
+	secrets, err := cluster.manager.clusterSecrets(ctx, cluster.name, cluster.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]SecretInfo, 0, len(secrets))
+
+	for _, secret := range secrets {
+		info := SecretInfo{
+			Name:      secret.Name,
+			Type:      secret.Type,
+			CreatedAt: secret.CreationTimestamp.Time,
+			Age:       time.Since(secret.CreationTimestamp.Time),
+		}
+
+		for _, suffix := range clusterSecretSuffixes {
+			if secret.Name == fmt.Sprintf("%s-%s", cluster.name, suffix) {
+				info.Purpose = suffix
+
+				break
+			}
+		}
+
+		for _, data := range secret.Data {
+			notAfter := earliestCertificateExpiry(data)
+
+			if notAfter != nil && (info.NotAfter == nil || notAfter.Before(*info.NotAfter)) {
+				info.NotAfter = notAfter
+			}
+		}
+
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// clusterSecrets fetches the cluster secrets by the cluster name label and by the well-known names sorted by name.
+//
+// Secrets found by the name should either have the cluster name label or the owner reference to the Cluster,
+// so that the user secrets which happen to match the naming scheme are never picked up.
+func (clusterAPI *Manager) clusterSecrets(ctx context.Context, name, namespace string) ([]corev1.Secret, error) {
+	secrets := map[string]corev1.Secret{}
+
+	labeled, err := clusterAPI.clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", clusterv1.ClusterLabelName, name),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range labeled.Items {
+		secrets[secret.Name] = secret
+	}
+
+	for _, suffix := range clusterSecretSuffixes {
+		secretName := fmt.Sprintf("%s-%s", name, suffix)
+
+		if _, ok := secrets[secretName]; ok {
+			continue
+		}
+
+		var secret *corev1.Secret
+
+		secret, err = clusterAPI.clientset.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		if !ownedByCluster(secret, name) {
+			continue
+		}
+
+		secrets[secretName] = *secret
+	}
+
+	res := make([]corev1.Secret, 0, len(secrets))
+
+	for _, secret := range secrets {
+		res = append(res, secret)
+	}
+
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+
+	return res, nil
+}
+
+// ownedByCluster checks if the object is labeled with the cluster name or has the owner reference to the Cluster.
+func ownedByCluster(obj metav1.Object, clusterName string) bool {
+	if obj.GetLabels()[clusterv1.ClusterLabelName] == clusterName {
+		return true
+	}
+
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.Kind == "Cluster" && ref.Name == clusterName && strings.HasPrefix(ref.APIVersion, clusterv1.GroupVersion.Group+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// earliestCertificateExpiry parses PEM certificates in the data and returns the earliest expiration time.
+func earliestCertificateExpiry(data []byte) *time.Time {
+	if !strings.Contains(string(data), "-----BEGIN CERTIFICATE-----") {
+		return nil
+	}
+
+	var res *time.Time
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			return res
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		if res == nil || cert.NotAfter.Before(*res) {
+			notAfter := cert.NotAfter
+			res = &notAfter
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestOwnedByCluster(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		meta     metav1.ObjectMeta
+		expected bool
+	}{
+		{
+			name:     "label",
+			meta:     metav1.ObjectMeta{Labels: map[string]string{clusterv1.ClusterLabelName: "prod"}},
+			expected: true,
+		},
+		{
+			name:     "owner",
+			meta:     metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{APIVersion: "cluster.x-k8s.io/v1beta1", Kind: "Cluster", Name: "prod"}}},
+			expected: true,
+		},
+		{
+			name: "other cluster",
+			meta: metav1.ObjectMeta{
+				Labels:          map[string]string{clusterv1.ClusterLabelName: "staging"},
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "cluster.x-k8s.io/v1beta1", Kind: "Cluster", Name: "staging"}},
+			},
+		},
+		{
+			name: "other group cluster",
+			meta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: "Cluster", Name: "prod"}}},
+		},
+		{
+			name: "none",
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			if res := ownedByCluster(&tt.meta, "prod"); res != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
+
+func TestEarliestCertificateExpiry(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notAfter := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	certificate := func(notAfter time.Time) []byte {
+		template := x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "kubernetes"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     notAfter,
+		}
+
+		der, createErr := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+		if createErr != nil {
+			t.Fatal(createErr)
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	for _, tt := range []struct {
+		name     string
+		data     []byte
+		expected *time.Time
+	}{
+		{
+			name: "no certificates",
+			data: privateKey,
+		},
+		{
+			name:     "key pair",
+			data:     append(append([]byte{}, privateKey...), certificate(notAfter)...),
+			expected: &notAfter,
+		},
+		{
+			name:     "bundle",
+			data:     append(certificate(notAfter.Add(time.Hour)), certificate(notAfter)...),
+			expected: &notAfter,
+		},
+		{
+			name: "malformed",
+			data: []byte("-----BEGIN CERTIFICATE-----\nbm90IGEgY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n"),
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			res := earliestCertificateExpiry(tt.data)
+
+			switch {
+			case tt.expected == nil && res != nil:
+				t.Errorf("expected no expiry, got %s", res)
+			case tt.expected != nil && (res == nil || !res.Equal(*tt.expected)):
+				t.Errorf("expected %s, got %v", tt.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+)
+
+// BootstrapOptions configures management cluster bootstrap.
+type BootstrapOptions struct {
+	Options
+}
+
+// Bootstrap creates the Manager, installs all components and waits for providers webhooks to be ready,
+// cert-manager webhook is waited for by Install.
+//
+// It is safe to call Bootstrap again against already bootstrapped management cluster.
+func Bootstrap(ctx context.Context, opts BootstrapOptions) (*Manager, error) {
+	fmt.Printf("connecting to the management cluster\n")
+
+	clusterAPI, err := NewManager(ctx, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("installing cluster API components\n")
+
+	if err = clusterAPI.Install(ctx); err != nil {
+		clusterAPI.Close() //nolint:errcheck
+
+		return nil, err
+	}
+
+	fmt.Printf("waiting for provider webhooks\n")
+
+	if err = clusterAPI.waitCoreProviders(ctx); err != nil {
+		clusterAPI.Close() //nolint:errcheck
+
+		return nil, err
+	}
+
+	fmt.Printf("management cluster is ready, cluster API version %s\n", clusterAPI.Version())
+
+	return clusterAPI, nil
+}
+
+// waitCoreProviders waits for core, bootstrap and control plane providers controllers which serve webhooks.
+func (clusterAPI *Manager) waitCoreProviders(ctx context.Context) error {
+	var labels []string
+
+	for providerType, providers := range map[clusterctlv1.ProviderType][]string{
+		clusterctlv1.CoreProviderType:         {clusterAPI.options.CoreProvider},
+		clusterctlv1.BootstrapProviderType:    clusterAPI.options.BootstrapProviders,
+		clusterctlv1.ControlPlaneProviderType: clusterAPI.options.ControlPlaneProviders,
+	} {
+		for _, provider := range providers {
+			if provider == "" {
+				continue
+			}
+
+			name, err := parseProvider(provider)
+			if err != nil {
+				return err
+			}
+
+			labels = append(labels, clusterctlv1.ManifestLabel(name, providerType))
+		}
+	}
+
+	for _, label := range labels {
+		deployments, err := clusterAPI.providerDeployments(ctx, label)
+		if err != nil {
+			return err
+		}
+
+		for _, deployment := range deployments {
+			if err = clusterAPI.waitDeploymentRollout(ctx, deployment.Namespace, deployment.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
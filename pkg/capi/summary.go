@@ -0,0 +1,113 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NamespaceSummary is the overview of the clusters in a namespace.
+type NamespaceSummary struct {
+	// ClustersByPhase maps cluster phase to the number of clusters, clusters without phase are counted as Pending.
+	ClustersByPhase map[string]int
+	Clusters        int
+	Machines        int
+
+	// InfrastructureKinds and ControlPlaneKinds are sorted kinds referenced by the clusters, e.g. AWSCluster, TalosControlPlane.
+	InfrastructureKinds []string
+	ControlPlaneKinds   []string
+}
+
+// NamespaceSummary counts clusters and machines in the namespace and collects providers used by the clusters.
+//
+// Machines are listed as metadata only to keep the call cheap for namespaces with lots of machines.
+func (clusterAPI *Manager) NamespaceSummary(ctx context.Context, namespace string) (*NamespaceSummary, error) {
+	var clusters unstructured.UnstructuredList
+
+	clusters.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	if err := clusterAPI.runtimeClient.List(ctx, &clusters, runtimeclient.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	summary := &NamespaceSummary{
+		ClustersByPhase: map[string]int{},
+		Clusters:        len(clusters.Items),
+	}
+
+	infrastructureKinds := map[string]struct{}{}
+	controlPlaneKinds := map[string]struct{}{}
+
+	for _, cluster := range clusters.Items {
+		phase, _, err := unstructured.NestedString(cluster.Object, "status", "phase")
+		if err != nil {
+			return nil, err
+		}
+
+		if phase == "" {
+			phase = "Pending"
+		}
+
+		summary.ClustersByPhase[phase]++
+
+		for _, ref := range []struct {
+			kinds map[string]struct{}
+			field string
+		}{
+			{infrastructureKinds, "infrastructureRef"},
+			{controlPlaneKinds, "controlPlaneRef"},
+		} {
+			kind, _, err := unstructured.NestedString(cluster.Object, "spec", ref.field, "kind")
+			if err != nil {
+				return nil, err
+			}
+
+			if kind != "" {
+				ref.kinds[kind] = struct{}{}
+			}
+		}
+	}
+
+	summary.InfrastructureKinds = sortedKeys(infrastructureKinds)
+	summary.ControlPlaneKinds = sortedKeys(controlPlaneKinds)
+
+	var machines metav1.PartialObjectMetadataList
+
+	machines.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Machine",
+		Version: clusterAPI.version,
+	})
+
+	if err := clusterAPI.runtimeClient.List(ctx, &machines, runtimeclient.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	summary.Machines = len(machines.Items)
+
+	return summary, nil
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	res := make([]string, 0, len(m))
+
+	for key := range m {
+		res = append(res, key)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
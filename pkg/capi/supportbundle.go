@@ -0,0 +1,229 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	clusterctlv1 "sigs.k8s.io/cluster-api/cmd/clusterctl/api/v1alpha3"
+	"sigs.k8s.io/yaml"
+)
+
+// supportBundleLogsSince limits provider controller logs collected into the support bundle.
+const supportBundleLogsSince = 24 * time.Hour
+
+// SupportBundle writes tar archive with the cluster CAPI objects (without secrets and with the keys, tokens
+// and other sensitive values in the objects redacted), events of these objects,
+// machine statuses and provider controller log lines mentioning the cluster namespace.
+func (cluster *Cluster) SupportBundle(ctx context.Context, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	objects, err := cluster.Export(ctx, ExportRedacted())
+	if err != nil {
+		return err
+	}
+
+	if err = writeTarFile(tw, "objects.yaml", objects); err != nil {
+		return err
+	}
+
+	events, err := cluster.events(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = writeTarYAML(tw, "events.yaml", events); err != nil {
+		return err
+	}
+
+	machines, err := cluster.machineStatuses(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = writeTarYAML(tw, "machines.yaml", machines); err != nil {
+		return err
+	}
+
+	if err = cluster.writeProviderLogs(ctx, tw); err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// events returns the events of the cluster objects.
+func (cluster *Cluster) events(ctx context.Context) ([]corev1.Event, error) {
+	objects, err := cluster.objects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uids := make(map[types.UID]struct{}, len(objects))
+
+	for _, obj := range objects {
+		uids[obj.GetUID()] = struct{}{}
+	}
+
+	events, err := cluster.manager.clientset.CoreV1().Events(cluster.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	res := []corev1.Event{}
+
+	for _, event := range events.Items {
+		if _, ok := uids[event.InvolvedObject.UID]; ok {
+			event.ManagedFields = nil
+
+			res = append(res, event)
+		}
+	}
+
+	return res, nil
+}
+
+// machineStatuses returns the status of each cluster machine.
+func (cluster *Cluster) machineStatuses(ctx context.Context) ([]map[string]interface{}, error) {
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]map[string]interface{}, 0, len(machines.Items))
+
+	for _, machine := range machines.Items {
+		status, _, err := unstructured.NestedMap(machine.Object, "status")
+		if err != nil {
+			return nil, err
+		}
+
+		_, controlPlane := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]
+
+		res = append(res, map[string]interface{}{
+			"name":         machine.GetName(),
+			"controlPlane": controlPlane,
+			"status":       status,
+		})
+	}
+
+	return res, nil
+}
+
+// writeProviderLogs collects the provider controller log lines which mention the cluster namespace.
+func (cluster *Cluster) writeProviderLogs(ctx context.Context, tw *tar.Writer) error {
+	providers, err := cluster.manager.listProviders(ctx)
+	if err != nil {
+		return err
+	}
+
+	since := int64(supportBundleLogsSince.Seconds())
+
+	for _, provider := range providers {
+		deployments, err := cluster.manager.providerDeployments(ctx, clusterctlv1.ManifestLabel(provider.ProviderName, provider.GetProviderType()))
+		if err != nil {
+			return err
+		}
+
+		for _, deployment := range deployments {
+			selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+			if err != nil {
+				return err
+			}
+
+			pods, err := cluster.manager.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return err
+			}
+
+			for _, pod := range pods.Items {
+				for _, container := range pod.Spec.Containers {
+					data := cluster.containerLogs(ctx, pod, container.Name, since)
+
+					if err = writeTarFile(tw, path.Join("logs", pod.Namespace, pod.Name, container.Name+".log"), data); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// containerLogs reads the container log lines which mention the cluster namespace.
+//
+// Logs of a single container might be unavailable, e.g. if the pod is restarting, so the error is returned as the log contents.
+func (cluster *Cluster) containerLogs(ctx context.Context, pod corev1.Pod, container string, since int64) []byte {
+	logs, err := cluster.manager.clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container:    container,
+		SinceSeconds: &since,
+	}).Stream(ctx)
+	if err != nil {
+		return []byte(fmt.Sprintf("failed to read pod %s/%s container %s logs: %s\n", pod.Namespace, pod.Name, container, err))
+	}
+
+	defer logs.Close() //nolint:errcheck
+
+	data, err := filterLines(logs, cluster.namespace)
+	if err != nil {
+		return append(data, []byte(fmt.Sprintf("failed to read pod %s/%s container %s logs: %s\n", pod.Namespace, pod.Name, container, err))...)
+	}
+
+	return data
+}
+
+func filterLines(r io.Reader, substr string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), substr) {
+			buf.Write(scanner.Bytes())
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), scanner.Err()
+}
+
+func writeTarYAML(tw *tar.Writer, name string, obj interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	return writeTarFile(tw, name, data)
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	_, err := tw.Write(data)
+
+	return err
+}
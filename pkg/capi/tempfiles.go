@@ -0,0 +1,171 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	templateTempFilePrefix = "capi-utils-template"
+	ociTempDirPrefix       = "capi-utils-oci"
+
+	// tempDirOwnerFile records the host and the pid of the process using the temp directory.
+	tempDirOwnerFile = ".owner"
+
+	// tempFilesMaxAge is the age after which temp files are considered leaked by a crashed run.
+	tempFilesMaxAge = 24 * time.Hour
+)
+
+// tempDir returns the directory for the temp files.
+func (clusterAPI *Manager) tempDir() string {
+	if clusterAPI.options.TempDir != "" {
+		return clusterAPI.options.TempDir
+	}
+
+	return os.TempDir()
+}
+
+// createTempDir creates the temp directory owned by the Manager, it is removed on Close.
+func (clusterAPI *Manager) createTempDir(prefix string) (string, error) {
+	dir, err := ioutil.TempDir(clusterAPI.tempDir(), prefix)
+	if err != nil {
+		return "", err
+	}
+
+	clusterAPI.tempDirs = append(clusterAPI.tempDirs, dir)
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	owner := fmt.Sprintf("%s %d", hostname, os.Getpid())
+
+	if err = ioutil.WriteFile(filepath.Join(dir, tempDirOwnerFile), []byte(owner), 0o600); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// checkTempDir verifies that temp files can be created in the directory.
+func checkTempDir(dir string) error {
+	f, err := ioutil.TempFile(dir, templateTempFilePrefix)
+	if err != nil {
+		return fmt.Errorf("temp directory %s is not writable %w", dir, err)
+	}
+
+	f.Close() //nolint:errcheck
+
+	return os.Remove(f.Name())
+}
+
+// CleanupTempFiles removes stale temp files and directories left by the previous runs.
+//
+// The library doesn't write kubeconfigs to the temp files, the temp files are the rendered cluster templates
+// and the pulled OCI providers. They might leak if the process crashes before Manager.Close,
+// so long-running processes should call CleanupTempFiles on startup.
+func CleanupTempFiles() error {
+	return CleanupTempFilesIn(os.TempDir())
+}
+
+// CleanupTempFilesIn removes stale temp files from the directory, it should be used if Options.TempDir is set.
+//
+// Files older than a day are removed, unless they are owned by a process which is still running on this host,
+// as the Manager keeps the pulled OCI providers until Close.
+func CleanupTempFilesIn(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), templateTempFilePrefix) && !strings.HasPrefix(entry.Name(), ociTempDirPrefix) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if time.Since(info.ModTime()) < tempFilesMaxAge {
+			continue
+		}
+
+		if entry.IsDir() && ownerRunning(path) {
+			continue
+		}
+
+		if err = os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ownerRunning checks if the process which created the temp directory is still running on this host.
+func ownerRunning(dir string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(dir, tempDirOwnerFile))
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return false
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || fields[0] != hostname {
+		return false
+	}
+
+	pid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return false
+	}
+
+	if pid == os.Getpid() {
+		return true
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	// FindProcess fails on Windows if the process doesn't exist, signals are not supported there
+	if runtime.GOOS == "windows" {
+		return true
+	}
+
+	err = process.Signal(syscall.Signal(0))
+
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
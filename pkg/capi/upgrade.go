@@ -0,0 +1,202 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/version"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// UpgradeKubernetes upgrades control plane and then all MachineDeployments to the Kubernetes version.
+//
+// Kubernetes version skew policy allows upgrading only one minor version at a time.
+// With DryRun, all updates are validated by the server, but the upgrade is not started.
+func (cluster *Cluster) UpgradeKubernetes(ctx context.Context, kubernetesVersion string, setters ...OperationOption) error {
+	opts := newOperationOptions(setters)
+
+	current, err := cluster.KubernetesVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = checkVersionSkew(current, kubernetesVersion); err != nil {
+		return err
+	}
+
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefix := ""
+	if opts.DryRun {
+		prefix = "dry run: "
+	}
+
+	fmt.Printf("%supgrading control plane %s from %s to %s\n", prefix, controlPlane.GetName(), current, kubernetesVersion)
+
+	if err = unstructured.SetNestedField(controlPlane.Object, kubernetesVersion, "spec", "version"); err != nil {
+		return err
+	}
+
+	if err = cluster.manager.runtimeClient.Update(ctx, controlPlane, opts.updateOptions()...); err != nil {
+		return err
+	}
+
+	if !opts.DryRun {
+		if err = cluster.waitControlPlaneVersion(ctx, kubernetesVersion); err != nil {
+			return err
+		}
+	}
+
+	machineDeployments, err := cluster.Workers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range machineDeployments.Items {
+		machineDeployment := &machineDeployments.Items[i]
+
+		fmt.Printf("%supgrading machine deployment %s to %s\n", prefix, machineDeployment.GetName(), kubernetesVersion)
+
+		if err = unstructured.SetNestedField(machineDeployment.Object, kubernetesVersion, "spec", "template", "spec", "version"); err != nil {
+			return err
+		}
+
+		if err = cluster.manager.runtimeClient.Update(ctx, machineDeployment, opts.updateOptions()...); err != nil {
+			return err
+		}
+
+		if opts.DryRun {
+			continue
+		}
+
+		if err = cluster.WaitForMachineDeploymentRollout(ctx, machineDeployment.GetName()); err != nil {
+			return err
+		}
+	}
+
+	if opts.DryRun {
+		fmt.Printf("dry run: cluster %s can be upgraded to %s\n", cluster.name, kubernetesVersion)
+
+		return nil
+	}
+
+	fmt.Printf("cluster %s is upgraded to %s\n", cluster.name, kubernetesVersion)
+
+	return nil
+}
+
+func (cluster *Cluster) waitControlPlaneVersion(ctx context.Context, kubernetesVersion string) error {
+	return retry.Constant(60*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		controlPlane, err := cluster.ControlPlanes(ctx)
+		if err != nil {
+			return cluster.manager.retryable(err)
+		}
+
+		current, _, err := unstructured.NestedString(controlPlane.Object, "status", "version")
+		if err != nil {
+			return err
+		}
+
+		if current != kubernetesVersion {
+			return retry.ExpectedError(fmt.Errorf("control plane version is %s, expected %s", current, kubernetesVersion))
+		}
+
+		return checkReplicasReady(*controlPlane)
+	})
+}
+
+// checkVersionSkew verifies that the upgrade doesn't skip minor versions and is not a downgrade.
+func checkVersionSkew(from, to string) error {
+	fromVersion, err := version.ParseSemantic(from)
+	if err != nil {
+		return fmt.Errorf("failed to parse current Kubernetes version %w", err)
+	}
+
+	toVersion, err := version.ParseSemantic(to)
+	if err != nil {
+		return fmt.Errorf("failed to parse target Kubernetes version %w", err)
+	}
+
+	if toVersion.LessThan(fromVersion) {
+		return fmt.Errorf("downgrading Kubernetes from %s to %s is not supported", from, to)
+	}
+
+	if toVersion.Major() != fromVersion.Major() || toVersion.Minor() > fromVersion.Minor()+1 {
+		return fmt.Errorf("upgrading Kubernetes from %s to %s skips minor versions, upgrade one minor version at a time", from, to)
+	}
+
+	return nil
+}
+
+// UpgradeStatus is the Kubernetes upgrade progress of the cluster machines.
+type UpgradeStatus struct {
+	TargetVersion       string
+	ControlPlaneUpdated int
+	ControlPlaneTotal   int
+	WorkersUpdated      int
+	WorkersTotal        int
+}
+
+// Done returns true if all machines are rolled to the target version.
+func (status *UpgradeStatus) Done() bool {
+	return status.ControlPlaneUpdated == status.ControlPlaneTotal && status.WorkersUpdated == status.WorkersTotal
+}
+
+// UpgradeProgress reports how many machines run the control plane Kubernetes version.
+//
+// It can be polled while UpgradeKubernetes is running.
+func (cluster *Cluster) UpgradeProgress(ctx context.Context) (*UpgradeStatus, error) {
+	controlPlane, err := cluster.ControlPlanes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &UpgradeStatus{}
+
+	if status.TargetVersion, _, err = unstructured.NestedString(controlPlane.Object, "spec", "version"); err != nil {
+		return nil, err
+	}
+
+	machines, err := cluster.machines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, machine := range machines.Items {
+		var machineVersion string
+
+		if machineVersion, _, err = unstructured.NestedString(machine.Object, "spec", "version"); err != nil {
+			return nil, err
+		}
+
+		updated := machineVersion == status.TargetVersion
+
+		if _, ok := machine.GetLabels()[clusterv1.MachineControlPlaneLabelName]; ok {
+			status.ControlPlaneTotal++
+
+			if updated {
+				status.ControlPlaneUpdated++
+			}
+
+			continue
+		}
+
+		status.WorkersTotal++
+
+		if updated {
+			status.WorkersUpdated++
+		}
+	}
+
+	return status, nil
+}
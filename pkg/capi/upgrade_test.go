@@ -0,0 +1,32 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import "testing"
+
+func TestCheckVersionSkew(t *testing.T) {
+	for _, tt := range []struct {
+		from string
+		to   string
+		err  bool
+	}{
+		{from: "v1.22.3", to: "v1.23.5"},
+		{from: "v1.23.4", to: "v1.23.5"},
+		{from: "v1.23.5", to: "v1.23.5"},
+		{from: "v1.22.3", to: "v1.24.0", err: true},
+		{from: "v1.23.5", to: "v1.22.3", err: true},
+		{from: "v1.23.5", to: "v2.0.0", err: true},
+		{from: "v1.23", to: "v1.23.5", err: true},
+		{from: "v1.23.5", to: "latest", err: true},
+	} {
+		tt := tt
+
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			if err := checkVersionSkew(tt.from, tt.to); (err != nil) != tt.err {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// certManagerInjectAnnotation points cert-manager CA injector to the certificate of the webhook.
+const certManagerInjectAnnotation = "cert-manager.io/inject-ca-from"
+
+// webhookConfiguration is the provider webhook configuration with the certificate it is served with.
+type webhookConfiguration struct {
+	name        string
+	certificate types.NamespacedName
+	clients     []admissionregistrationv1.WebhookClientConfig
+}
+
+// RotateWebhookCerts makes cert-manager reissue the provider webhook certificates and waits for the webhooks to be healthy again.
+//
+// Certificates are reissued by deleting their secrets, so the rotation is refused while any cluster is in the middle of an operation.
+func (clusterAPI *Manager) RotateWebhookCerts(ctx context.Context) error {
+	if err := clusterAPI.checkClustersIdle(ctx); err != nil {
+		return err
+	}
+
+	webhooks, err := clusterAPI.providerWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(webhooks) == 0 {
+		return fmt.Errorf("no provider webhooks with cert-manager issued certificates found")
+	}
+
+	certificates := map[types.NamespacedName]string{}
+
+	for _, webhook := range webhooks {
+		if _, ok := certificates[webhook.certificate]; ok {
+			continue
+		}
+
+		var secretName string
+
+		if secretName, err = clusterAPI.certificateSecretName(ctx, webhook.certificate); err != nil {
+			return err
+		}
+
+		certificates[webhook.certificate] = secretName
+	}
+
+	for certificate, secretName := range certificates {
+		var secret *metav1.PartialObjectMetadata
+
+		secret, err = clusterAPI.secretMetadata(ctx, certificate.Namespace, secretName)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("reissuing webhook certificate %s\n", certificate)
+
+		if err = clusterAPI.clientset.CoreV1().Secrets(certificate.Namespace).Delete(ctx, secretName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete certificate %s secret %w", certificate, err)
+		}
+
+		if err = clusterAPI.waitSecretReissued(ctx, certificate.Namespace, secretName, secret.UID); err != nil {
+			return fmt.Errorf("certificate %s was not reissued %w", certificate, err)
+		}
+	}
+
+	return clusterAPI.waitWebhooksHealthy(ctx, certificates)
+}
+
+// checkClustersIdle returns an error if any of the clusters is being created, deleted, scaled or upgraded.
+func (clusterAPI *Manager) checkClustersIdle(ctx context.Context) error {
+	var clusters unstructured.UnstructuredList
+
+	clusters.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cluster.x-k8s.io",
+		Kind:    "Cluster",
+		Version: clusterAPI.version,
+	})
+
+	if err := clusterAPI.runtimeClient.List(ctx, &clusters); err != nil {
+		return err
+	}
+
+	for i := range clusters.Items {
+		obj := &clusters.Items[i]
+
+		cluster := &Cluster{
+			manager:   clusterAPI,
+			name:      obj.GetName(),
+			namespace: obj.GetNamespace(),
+			cluster:   *obj,
+		}
+
+		reason, err := cluster.busyReason(ctx)
+		if err != nil {
+			return err
+		}
+
+		if reason != "" {
+			return fmt.Errorf("cluster %s/%s operation is in progress: %s", obj.GetNamespace(), obj.GetName(), reason)
+		}
+	}
+
+	return nil
+}
+
+// providerWebhooks lists validating and mutating webhook configurations of the providers which have cert-manager injected CA.
+func (clusterAPI *Manager) providerWebhooks(ctx context.Context) ([]webhookConfiguration, error) {
+	opts := metav1.ListOptions{LabelSelector: clusterv1.ProviderLabelName}
+
+	validating, err := clusterAPI.clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	mutating, err := clusterAPI.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []webhookConfiguration{}
+
+	add := func(meta metav1.ObjectMeta, clients []admissionregistrationv1.WebhookClientConfig) {
+		certificate, ok := meta.Annotations[certManagerInjectAnnotation]
+		if !ok {
+			return
+		}
+
+		parts := strings.SplitN(certificate, "/", 2)
+		if len(parts) != 2 {
+			return
+		}
+
+		res = append(res, webhookConfiguration{
+			name:        meta.Name,
+			certificate: types.NamespacedName{Namespace: parts[0], Name: parts[1]},
+			clients:     clients,
+		})
+	}
+
+	for _, configuration := range validating.Items {
+		clients := make([]admissionregistrationv1.WebhookClientConfig, 0, len(configuration.Webhooks))
+
+		for _, webhook := range configuration.Webhooks {
+			clients = append(clients, webhook.ClientConfig)
+		}
+
+		add(configuration.ObjectMeta, clients)
+	}
+
+	for _, configuration := range mutating.Items {
+		clients := make([]admissionregistrationv1.WebhookClientConfig, 0, len(configuration.Webhooks))
+
+		for _, webhook := range configuration.Webhooks {
+			clients = append(clients, webhook.ClientConfig)
+		}
+
+		add(configuration.ObjectMeta, clients)
+	}
+
+	return res, nil
+}
+
+// certificateSecretName returns the name of the secret cert-manager stores the certificate in.
+func (clusterAPI *Manager) certificateSecretName(ctx context.Context, certificate types.NamespacedName) (string, error) {
+	var obj unstructured.Unstructured
+
+	obj.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "cert-manager.io",
+		Kind:    "Certificate",
+		Version: "v1",
+	})
+
+	if err := clusterAPI.runtimeClient.Get(ctx, certificate, &obj); err != nil {
+		return "", fmt.Errorf("failed to get certificate %s %w", certificate, err)
+	}
+
+	secretName, _, err := unstructured.NestedString(obj.Object, "spec", "secretName")
+	if err != nil {
+		return "", err
+	}
+
+	if secretName == "" {
+		return "", fmt.Errorf("certificate %s has no secret name", certificate)
+	}
+
+	return secretName, nil
+}
+
+// secretMetadata returns the secret metadata, empty metadata is returned if the secret doesn't exist.
+func (clusterAPI *Manager) secretMetadata(ctx context.Context, namespace, name string) (*metav1.PartialObjectMetadata, error) {
+	secret, err := clusterAPI.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return &metav1.PartialObjectMetadata{}, nil
+		}
+
+		return nil, err
+	}
+
+	return &metav1.PartialObjectMetadata{ObjectMeta: secret.ObjectMeta}, nil
+}
+
+// waitSecretReissued waits until the deleted certificate secret is created again with the certificate data.
+func (clusterAPI *Manager) waitSecretReissued(ctx context.Context, namespace, name string, oldUID types.UID) error {
+	return retry.Constant(5*time.Minute, retry.WithUnits(5*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		secret, err := clusterAPI.clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				return retry.ExpectedError(err)
+			}
+
+			return clusterAPI.retryable(err)
+		}
+
+		if secret.UID == oldUID {
+			return retry.ExpectedError(fmt.Errorf("secret %s/%s is not deleted yet", namespace, name))
+		}
+
+		if len(secret.Data["tls.crt"]) == 0 || len(secret.Data["ca.crt"]) == 0 {
+			return retry.ExpectedError(fmt.Errorf("secret %s/%s has no certificate yet", namespace, name))
+		}
+
+		return nil
+	})
+}
+
+// waitWebhooksHealthy waits until the new CA is injected into the webhook configurations and the webhook services have endpoints.
+func (clusterAPI *Manager) waitWebhooksHealthy(ctx context.Context, certificates map[types.NamespacedName]string) error {
+	return retry.Constant(10*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).RetryWithContext(ctx, func(ctx context.Context) error {
+		webhooks, err := clusterAPI.providerWebhooks(ctx)
+		if err != nil {
+			return clusterAPI.retryable(err)
+		}
+
+		for _, webhook := range webhooks {
+			secretName, ok := certificates[webhook.certificate]
+			if !ok {
+				continue
+			}
+
+			var secret *corev1.Secret
+
+			secret, err = clusterAPI.clientset.CoreV1().Secrets(webhook.certificate.Namespace).Get(ctx, secretName, metav1.GetOptions{})
+			if err != nil {
+				return clusterAPI.retryable(err)
+			}
+
+			for _, client := range webhook.clients {
+				if !bytes.Equal(client.CABundle, secret.Data["ca.crt"]) {
+					return retry.ExpectedError(fmt.Errorf("webhook configuration %s CA bundle is not updated yet", webhook.name))
+				}
+
+				if client.Service == nil {
+					continue
+				}
+
+				if err = clusterAPI.checkServiceEndpoints(ctx, client.Service.Namespace, client.Service.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkServiceEndpoints returns expected error if the service has no ready endpoints.
+func (clusterAPI *Manager) checkServiceEndpoints(ctx context.Context, namespace, name string) error {
+	endpoints, err := clusterAPI.clientset.CoreV1().Endpoints(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return retry.ExpectedError(err)
+		}
+
+		return clusterAPI.retryable(err)
+	}
+
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return nil
+		}
+	}
+
+	return retry.ExpectedError(fmt.Errorf("webhook service %s/%s has no ready endpoints", namespace, name))
+}
@@ -8,11 +8,26 @@ package constants
 const (
 	// CoreProviderName is the string id of the core provider.
 	CoreProviderName = "cluster-api"
+	// CoreProviderDefaultVersion is the core provider version installed when no version is specified
+	// and the cluster-api module version capi-utils is built with is not available from the build info.
+	CoreProviderDefaultVersion = "v1.1.3"
 	// CoreCAPINamespace default core CAPI system namespace.
 	CoreCAPINamespace = "capi-system"
+	// CertManagerNamespace default cert-manager namespace.
+	CertManagerNamespace = "cert-manager"
 
 	// AWSProviderName is the string id of the AWS provider.
 	AWSProviderName = "aws"
 	// AWSCAPANamespace default AWS provider CAPI system namespace.
 	AWSCAPANamespace = "capa-system"
+
+	// GCPProviderName is the string id of the GCP provider.
+	GCPProviderName = "gcp"
+	// GCPCAPGNamespace default GCP provider CAPI system namespace.
+	GCPCAPGNamespace = "capg-system"
+
+	// AzureProviderName is the string id of the Azure provider.
+	AzureProviderName = "azure"
+	// AzureCAPZNamespace default Azure provider CAPI system namespace.
+	AzureCAPZNamespace = "capz-system"
 )